@@ -0,0 +1,209 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// adminPrefix is the path prefix of all admin endpoints
+const adminPrefix = "/v1/admin/"
+
+// adminMux contains all admin endpoints. Admin files add their endpoints
+// during init through adminHandle.
+var adminMux = http.NewServeMux()
+
+func init() {
+	adminHandle(http.MethodGet, "round", adminRound)
+	adminHandle(http.MethodPost, "round/pause", adminRoundPause)
+	adminHandle(http.MethodPost, "round/resume", adminRoundResume)
+	adminHandle(http.MethodPost, "round/extend", adminRoundExtend)
+	adminHandle(http.MethodPost, "round/end", adminRoundEnd)
+}
+
+func adminInit(start chan bool, shutdown chan bool) {
+	if config().Admin.Addr == "" {
+		log.Info("admin disabled. no address configured")
+		return
+	}
+	if config().Admin.Token == "" {
+		log.Fatal("admin listener requires a token")
+	}
+
+	// bind the port right away, so the listener only reports listening once
+	// it accepts requests
+	listener, err := net.Listen("tcp", config().Admin.Addr)
+	if err != nil {
+		log.Fatal("admin listen failed", zap.String("addr", config().Admin.Addr), zap.Error(err))
+	}
+
+	srv := &http.Server{
+		Addr:    config().Admin.Addr,
+		Handler: adminMux,
+	}
+	services.Add(1)
+	listeners.Set("admin", listenerReady)
+
+	go func() {
+		// Wait for start signal
+		log.Info("admin ready. waiting for start signal")
+		<-start
+
+		go adminRun(srv, listener)
+		listeners.Set("admin", listenerListening)
+
+		// Shutdown server when signal is received
+		<-shutdown
+		ctx, cancel := shutdownContext()
+		defer cancel()
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			log.Warn("admin shutdown failed", zap.Error(err))
+		}
+		listeners.Set("admin", listenerStopped)
+		services.Done()
+	}()
+}
+
+func adminRun(srv *http.Server, listener net.Listener) {
+	var srvErr error
+
+	log.Info("accepting requests on admin listener")
+	if config().Admin.TLS.Active {
+		srvErr = srv.ServeTLS(listener, config().Admin.TLS.Cert, config().Admin.TLS.PKey)
+	} else {
+		srvErr = srv.Serve(listener)
+	}
+
+	if srvErr != nil && srvErr != http.ErrServerClosed {
+		log.Fatal("admin listen failed", zap.Error(srvErr))
+	}
+}
+
+// adminHandle registers h for the method and path (relative to adminPrefix).
+// Only requests carrying the admin token as bearer token reach h.
+func adminHandle(method, path string, h http.HandlerFunc) {
+	adminMux.HandleFunc(adminPrefix+path, func(w http.ResponseWriter, r *http.Request) {
+		ctx := log.With(zap.String("ip", r.RemoteAddr), zap.String("transport", "admin"))
+
+		if r.Method != method {
+			adminError(w, http.StatusMethodNotAllowed, "Only "+method+" requests are allowed")
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(config().Admin.Token)) != 1 {
+			ctx.Warn("admin request with invalid token", zap.String("path", r.URL.Path))
+			adminError(w, http.StatusUnauthorized, "Invalid admin token")
+			return
+		}
+
+		ctx.Info("admin request", zap.String("method", r.Method), zap.String("path", r.URL.Path))
+		h(w, r)
+	})
+}
+
+// adminDecode unmarshals the request body into v. If it fails an error is
+// written and false returned.
+func adminDecode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<16))
+	if err != nil {
+		adminError(w, http.StatusBadRequest, "Unable to read request body")
+		return false
+	}
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		adminError(w, http.StatusBadRequest, statusInvalidJSON)
+		return false
+	}
+	return true
+}
+
+func adminRespond(w http.ResponseWriter, status int, v interface{}) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		log.Error("failed to marshal admin response", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(append(buf, '\n'))
+	if err != nil {
+		log.Debug("failed to write admin response", zap.Error(err))
+	}
+}
+
+func adminError(w http.ResponseWriter, status int, msg string) {
+	adminRespond(w, status, struct {
+		Error string `json:"error"`
+	}{
+		msg,
+	})
+}
+
+type adminRoundResponse struct {
+	Phase     string `json:"phase"`
+	Remaining string `json:"remaining,omitempty"`
+}
+
+func adminRound(w http.ResponseWriter, r *http.Request) {
+	resp := adminRoundResponse{
+		Phase: round.Phase().String(),
+	}
+	if left, ok := round.Remaining(); ok {
+		resp.Remaining = left.Round(time.Second).String()
+	}
+	adminRespond(w, http.StatusOK, resp)
+}
+
+// adminRoundResult responds with the new round state or the error of the
+// state change.
+func adminRoundResult(w http.ResponseWriter, r *http.Request, err error) {
+	if err != nil {
+		adminError(w, http.StatusConflict, err.Error())
+		return
+	}
+	adminRound(w, r)
+}
+
+func adminRoundPause(w http.ResponseWriter, r *http.Request) {
+	adminRoundResult(w, r, round.Pause())
+}
+
+func adminRoundResume(w http.ResponseWriter, r *http.Request) {
+	adminRoundResult(w, r, round.Resume())
+}
+
+func adminRoundExtend(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Duration duration `json:"duration"`
+	}
+	if !adminDecode(w, r, &req) {
+		return
+	}
+	if req.Duration.Duration <= 0 {
+		adminError(w, http.StatusBadRequest, "'duration' must be positive")
+		return
+	}
+	adminRoundResult(w, r, round.Extend(req.Duration.Duration))
+}
+
+func adminRoundEnd(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		Reason string `json:"reason"`
+	}{
+		Reason: "The round has been ended by an admin",
+	}
+	if r.ContentLength != 0 && !adminDecode(w, r, &req) {
+		return
+	}
+	adminRoundResult(w, r, round.End(req.Reason))
+}
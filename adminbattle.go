@@ -0,0 +1,325 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/vikebot/vbgs/pkg/ntfydistr"
+	"github.com/vikebot/vbgs/vbge"
+	"go.uber.org/zap"
+)
+
+func init() {
+	adminHandle(http.MethodGet, "clients", adminClients)
+	adminHandle(http.MethodGet, "players", adminPlayers)
+	adminHandle(http.MethodGet, "map", adminMap)
+	adminHandle(http.MethodPost, "kick", adminKick)
+	adminHandle(http.MethodPost, "ban", adminBan)
+	adminHandle(http.MethodPost, "teleport", adminTeleport)
+	adminHandle(http.MethodPost, "respawn", adminRespawn)
+	adminHandle(http.MethodPost, "chat", adminChat)
+}
+
+type adminBotInfo struct {
+	UserID        int    `json:"user_id"`
+	IP            string `json:"ip"`
+	SDK           string `json:"sdk"`
+	SDKLink       string `json:"sdk_link"`
+	OS            string `json:"os"`
+	Authenticated bool   `json:"authenticated"`
+}
+
+type adminViewerInfo struct {
+	UserID int    `json:"user_id"`
+	WSID   string `json:"wsid"`
+	Mode   string `json:"mode"`
+}
+
+type adminClientsResponse struct {
+	Bots    []adminBotInfo    `json:"bots"`
+	Viewers []adminViewerInfo `json:"viewers"`
+}
+
+func adminClients(w http.ResponseWriter, r *http.Request) {
+	resp := adminClientsResponse{
+		Bots:    []adminBotInfo{},
+		Viewers: []adminViewerInfo{},
+	}
+
+	for _, c := range ntcpRegistry.All() {
+		info := adminBotInfo{
+			UserID:        c.UserID,
+			IP:            c.IP,
+			SDK:           c.SDK,
+			SDKLink:       c.SDKLink,
+			OS:            c.OS,
+			Authenticated: c.Authenticated,
+		}
+		resp.Bots = append(resp.Bots, info)
+	}
+	for _, c := range nwsRegistry.All() {
+		resp.Viewers = append(resp.Viewers, adminViewerInfo{
+			UserID: c.UserID,
+			WSID:   c.WSRqID,
+			Mode:   c.Mode,
+		})
+	}
+
+	sort.Slice(resp.Bots, func(i, j int) bool { return resp.Bots[i].UserID < resp.Bots[j].UserID })
+	sort.Slice(resp.Viewers, func(i, j int) bool { return resp.Viewers[i].UserID < resp.Viewers[j].UserID })
+
+	adminRespond(w, http.StatusOK, resp)
+}
+
+type adminPlayerInfo struct {
+	UserID        int           `json:"user_id"`
+	GRID          string        `json:"grid"`
+	Location      vbge.Location `json:"location"`
+	WatchDir      string        `json:"watchdir"`
+	Health        int           `json:"health"`
+	IsDefending   bool          `json:"defending"`
+	Kills         int           `json:"kills"`
+	Deaths        int           `json:"deaths"`
+	CharacterType string        `json:"charactertype"`
+	Connected     bool          `json:"connected"`
+	Banned        bool          `json:"banned"`
+}
+
+// adminPlayer collects the infos of p. The caller must hold the map's lock.
+func adminPlayer(p *vbge.Player) adminPlayerInfo {
+	return adminPlayerInfo{
+		UserID:        p.UserID,
+		GRID:          p.GRenderID,
+		Location:      *p.Location,
+		WatchDir:      p.WatchDir,
+		Health:        p.Health.HealthSynced(),
+		IsDefending:   p.IsDefending,
+		Kills:         p.Kills,
+		Deaths:        p.Deaths,
+		CharacterType: p.CharacterType,
+		Connected:     ntcpRegistry.Get(p.UserID) != nil,
+		Banned:        guard.UserBanned(p.UserID),
+	}
+}
+
+// adminPlayers lists all players or, if the `user_id` query parameter is set,
+// only the referenced player.
+func adminPlayers(w http.ResponseWriter, r *http.Request) {
+	battle.Map.SyncRoot.Lock()
+	defer battle.Map.SyncRoot.Unlock()
+
+	if id := r.URL.Query().Get("user_id"); id != "" {
+		userID, err := strconv.Atoi(id)
+		if err != nil {
+			adminError(w, http.StatusBadRequest, "'user_id' must be a number")
+			return
+		}
+		p, ok := battle.Players[userID]
+		if !ok {
+			adminError(w, http.StatusNotFound, "Unknown player")
+			return
+		}
+		adminRespond(w, http.StatusOK, adminPlayer(p))
+		return
+	}
+
+	players := []adminPlayerInfo{}
+	for _, p := range battle.Players {
+		players = append(players, adminPlayer(p))
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].UserID < players[j].UserID })
+
+	adminRespond(w, http.StatusOK, players)
+}
+
+type adminMapPlayer struct {
+	GRID     string        `json:"grid"`
+	Location vbge.Location `json:"location"`
+}
+
+type adminMapResponse struct {
+	Width   int              `json:"width"`
+	Height  int              `json:"height"`
+	Blocks  [][]string       `json:"blocks"`
+	Players []adminMapPlayer `json:"players"`
+}
+
+// adminMap dumps the blocks of the whole map together with the location of
+// all players.
+func adminMap(w http.ResponseWriter, r *http.Request) {
+	battle.Map.SyncRoot.Lock()
+	defer battle.Map.SyncRoot.Unlock()
+
+	resp := adminMapResponse{
+		Width:   battle.Map.Width,
+		Height:  battle.Map.Height,
+		Blocks:  make([][]string, len(battle.Map.Matrix)),
+		Players: []adminMapPlayer{},
+	}
+	for y, row := range battle.Map.Matrix {
+		resp.Blocks[y] = make([]string, len(row))
+		for x, be := range row {
+			resp.Blocks[y][x] = be.Blocktype
+			if be.HasResident() {
+				resp.Players = append(resp.Players, adminMapPlayer{
+					GRID:     be.Resident.GRenderID,
+					Location: vbge.Location{X: x, Y: y},
+				})
+			}
+		}
+	}
+
+	adminRespond(w, http.StatusOK, resp)
+}
+
+type adminKickRequest struct {
+	UserID int    `json:"user_id"`
+	Reason string `json:"reason"`
+}
+
+func adminKick(w http.ResponseWriter, r *http.Request) {
+	var req adminKickRequest
+	if !adminDecode(w, r, &req) {
+		return
+	}
+
+	c := ntcpRegistry.Get(req.UserID)
+	if c == nil {
+		adminError(w, http.StatusNotFound, "User isn't connected")
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "kicked by admin"
+	}
+	c.Kick(req.Reason)
+
+	adminRespond(w, http.StatusOK, struct{}{})
+}
+
+type adminBanRequest struct {
+	UserID   int      `json:"user_id"`
+	Duration duration `json:"duration"`
+	IP       bool     `json:"ip"`
+}
+
+// adminBan bans the user for the requested duration and kicks it's current
+// connection. If requested the connection's ip is banned as well.
+func adminBan(w http.ResponseWriter, r *http.Request) {
+	var req adminBanRequest
+	if !adminDecode(w, r, &req) {
+		return
+	}
+	if _, ok := battle.Players[req.UserID]; !ok {
+		adminError(w, http.StatusNotFound, "Unknown player")
+		return
+	}
+	if req.Duration.Duration <= 0 {
+		adminError(w, http.StatusBadRequest, "'duration' must be positive")
+		return
+	}
+
+	guard.BanUser(req.UserID, req.Duration.Duration)
+	if c := ntcpRegistry.Get(req.UserID); c != nil {
+		if req.IP {
+			guard.Ban(c.PureIP, req.Duration.Duration)
+		}
+		c.Kick("banned by admin")
+	}
+
+	log.Info("banned user",
+		zap.Int("user_id", req.UserID),
+		zap.Duration("duration", req.Duration.Duration),
+		zap.Bool("ip", req.IP))
+	adminRespond(w, http.StatusOK, struct{}{})
+}
+
+type adminTeleportRequest struct {
+	UserID int `json:"user_id"`
+	X      int `json:"x"`
+	Y      int `json:"y"`
+}
+
+func adminTeleport(w http.ResponseWriter, r *http.Request) {
+	var req adminTeleportRequest
+	if !adminDecode(w, r, &req) {
+		return
+	}
+
+	adminRelocate(w, req.UserID, &vbge.Location{
+		X: req.X,
+		Y: req.Y,
+	})
+}
+
+type adminRespawnRequest struct {
+	UserID int `json:"user_id"`
+}
+
+func adminRespawn(w http.ResponseWriter, r *http.Request) {
+	var req adminRespawnRequest
+	if !adminDecode(w, r, &req) {
+		return
+	}
+
+	adminRelocate(w, req.UserID, nil)
+}
+
+// adminRelocate moves the player to loc (or a random location if loc is nil)
+// and informs everybody around the old and new location. Like the transports
+// it holds the user's lock, so no op of the player works on the old location.
+func adminRelocate(w http.ResponseWriter, userID int, loc *vbge.Location) {
+	p, ok := battle.Players[userID]
+	if !ok {
+		adminError(w, http.StatusNotFound, "Unknown player")
+		return
+	}
+
+	lock := lockRegistry.Get(userID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	ctx := log.With(zap.Int("user_id", userID))
+	err := p.Relocate(loc,
+		func(p *vbge.Player, ngl vbge.NotifyGroupLocated) {
+			pushTeleportLeave(p, ngl, ctx)
+		},
+		func(p *vbge.Player, ngl vbge.NotifyGroupLocated) error {
+			return pushTeleportArrive(p, ngl, ctx)
+		})
+	if err != nil {
+		if _, ok := vbgeErrCodes[err]; ok {
+			adminError(w, http.StatusConflict, err.Error())
+			return
+		}
+		ctx.Error("relocating player failed", zap.Error(err))
+		adminError(w, http.StatusInternalServerError, statusInternalServerError)
+		return
+	}
+
+	battle.Map.SyncRoot.Lock()
+	defer battle.Map.SyncRoot.Unlock()
+	adminRespond(w, http.StatusOK, adminPlayer(p))
+}
+
+type adminChatRequest struct {
+	Prefix   string             `json:"prefix"`
+	Msg      string             `json:"msg"`
+	Severity ntfydistr.Severity `json:"severity"`
+}
+
+func adminChat(w http.ResponseWriter, r *http.Request) {
+	req := adminChatRequest{
+		Prefix: "admin",
+	}
+	if !adminDecode(w, r, &req) {
+		return
+	}
+	if req.Msg == "" {
+		adminError(w, http.StatusBadRequest, "'msg' mustn't be empty")
+		return
+	}
+
+	dist.PushChatPrefixedBroadcast(req.Prefix, req.Msg, req.Severity, log)
+	adminRespond(w, http.StatusOK, struct{}{})
+}
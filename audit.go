@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vikebot/vbgs/vbge"
+	"go.uber.org/zap"
+)
+
+// auditRecord is a single line of the audit log. It contains everything
+// needed to reconstruct what a bot requested and what happened afterwards.
+type auditRecord struct {
+	Time      time.Time         `json:"time"`
+	UserID    int               `json:"user_id"`
+	IP        string            `json:"ip"`
+	Transport string            `json:"transport"`
+	Op        string            `json:"op"`
+	Pc        uint32            `json:"pc,omitempty"`
+	Request   json.RawMessage   `json:"request"`
+	Responses []json.RawMessage `json:"responses"`
+	Latency   float64           `json:"latency_ms"`
+	Before    *auditPlayer      `json:"before,omitempty"`
+	After     *auditPlayer      `json:"after,omitempty"`
+}
+
+// auditPlayer is the state of the player before and after an op. Comparing
+// both shows the op's effect on the game.
+type auditPlayer struct {
+	Location  vbge.Location `json:"location"`
+	WatchDir  string        `json:"watchdir"`
+	Health    int           `json:"health"`
+	Defending bool          `json:"defending"`
+	Kills     int           `json:"kills"`
+	Deaths    int           `json:"deaths"`
+}
+
+func newAuditPlayer(p *vbge.Player) *auditPlayer {
+	if p == nil {
+		return nil
+	}
+
+	p.Map.SyncRoot.Lock()
+	defer p.Map.SyncRoot.Unlock()
+
+	return &auditPlayer{
+		Location:  *p.Location,
+		WatchDir:  p.WatchDir,
+		Health:    p.Health.HealthSynced(),
+		Defending: p.IsDefending,
+		Kills:     p.Kills,
+		Deaths:    p.Deaths,
+	}
+}
+
+// respond adds a response of the op to the record.
+func (r *auditRecord) respond(curType string, err *opError, d interface{}) {
+	res := batchResult{
+		Type:      curType,
+		ErrorInfo: newResponseError(err),
+		Obj:       d,
+	}
+	if err != nil {
+		res.Error = &err.Msg
+	}
+
+	buf, mErr := json.Marshal(res)
+	if mErr != nil {
+		return
+	}
+	r.Responses = append(r.Responses, buf)
+}
+
+// auditLog writes audit records as JSON lines into one file per user inside
+// the round's audit directory.
+type auditLog struct {
+	dir    string
+	files  map[int]*os.File
+	closed bool
+	baton  sync.Mutex
+}
+
+// audit is nil if auditing is disabled.
+var audit *auditLog
+
+func auditInit() {
+	if config().Log.Audit.Dir == "" {
+		log.Info("audit log disabled. no directory configured")
+		return
+	}
+
+	dir := auditRoundDir(config().Log.Audit.Dir, config().Battle.RoundID)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		log.Fatal("unable to create audit directory", zap.String("dir", dir), zap.Error(err))
+	}
+
+	audit = &auditLog{
+		dir:   dir,
+		files: map[int]*os.File{},
+	}
+	log.Info("writing audit log", zap.String("dir", dir))
+}
+
+func auditRoundDir(dir string, roundID int) string {
+	return filepath.Join(dir, "round-"+strconv.Itoa(roundID))
+}
+
+func auditUserFile(dir string, userID int) string {
+	return filepath.Join(dir, "user-"+strconv.Itoa(userID)+".jsonl")
+}
+
+// Write appends r to the user's audit file.
+func (a *auditLog) Write(r *auditRecord) {
+	buf, err := json.Marshal(r)
+	if err != nil {
+		log.Warn("failed to marshal audit record", zap.Error(err))
+		return
+	}
+
+	a.baton.Lock()
+	defer a.baton.Unlock()
+
+	// ops finishing after the shutdown mustn't reopen the files
+	if a.closed {
+		return
+	}
+
+	f, ok := a.files[r.UserID]
+	if !ok {
+		f, err = os.OpenFile(auditUserFile(a.dir, r.UserID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Error("failed to open audit file", zap.Int("user_id", r.UserID), zap.Error(err))
+			return
+		}
+		a.files[r.UserID] = f
+	}
+
+	_, err = f.Write(append(buf, '\n'))
+	if err != nil {
+		log.Error("failed to write audit record", zap.Int("user_id", r.UserID), zap.Error(err))
+	}
+}
+
+// Close closes all audit files. Records written afterwards are dropped.
+func (a *auditLog) Close() {
+	a.baton.Lock()
+	defer a.baton.Unlock()
+
+	for userID, f := range a.files {
+		err := f.Close()
+		if err != nil {
+			log.Warn("failed to close audit file", zap.Int("user_id", userID), zap.Error(err))
+		}
+	}
+	a.files = map[int]*os.File{}
+	a.closed = true
+}
+
+// auditMiddleware records all ops of authenticated clients. Handshake ops
+// are skipped, because their packets contain secrets (e.g. the roundticket).
+func auditMiddleware(next opHandlerFunc) opHandlerFunc {
+	return func(c *ntcpclient, o *op, data []byte) {
+		if audit == nil || o.Handshake || !c.Authenticated {
+			next(c, o, data)
+			return
+		}
+
+		r := &auditRecord{
+			Time:      time.Now().UTC(),
+			UserID:    c.UserID,
+			IP:        c.IP,
+			Transport: "ntcp",
+			Op:        o.Name,
+			Pc:        c.Pc,
+			Request:   json.RawMessage(data),
+			Responses: []json.RawMessage{},
+			Before:    newAuditPlayer(c.Player),
+		}
+		if c.Conn == nil {
+			r.Transport = "http"
+		}
+
+		// ops inside a batch get their own record
+		outer := c.audit
+		c.audit = r
+		next(c, o, data)
+		c.audit = outer
+
+		r.Latency = float64(time.Since(r.Time)) / float64(time.Millisecond)
+		r.After = newAuditPlayer(c.Player)
+		audit.Write(r)
+	}
+}
+
+// auditQuery filters the records of the audit command. Zero values match
+// everything.
+type auditQuery struct {
+	UserID int
+	Op     string
+	Since  time.Time
+	Until  time.Time
+}
+
+func (q auditQuery) Match(r *auditRecord) bool {
+	if q.UserID != 0 && r.UserID != q.UserID {
+		return false
+	}
+	if q.Op != "" && r.Op != q.Op {
+		return false
+	}
+	if !q.Since.IsZero() && r.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && r.Time.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+// auditSearch calls fn for every record of the round's audit directory
+// matching q.
+func auditSearch(dir string, q auditQuery, fn func(line []byte)) error {
+	files := []string{auditUserFile(dir, q.UserID)}
+	if q.UserID == 0 {
+		var err error
+		files, err = filepath.Glob(filepath.Join(dir, "user-*.jsonl"))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range files {
+		err := auditSearchFile(name, q, fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func auditSearchFile(name string, q auditQuery, fn func(line []byte)) error {
+	f, err := os.Open(name) /* #nosec G304 */
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for s.Scan() {
+		var r auditRecord
+		err = json.Unmarshal(s.Bytes(), &r)
+		if err != nil {
+			return err
+		}
+		if q.Match(&r) {
+			fn(s.Bytes())
+		}
+	}
+	return s.Err()
+}
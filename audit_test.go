@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vikebot/vbgs/vbge"
+)
+
+// withAudit writes the audit log into a temporary directory while fn runs.
+func withAudit(t *testing.T, fn func(dir string)) {
+	dir, err := ioutil.TempDir("", "vbgs-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	audit = &auditLog{
+		dir:   dir,
+		files: map[int]*os.File{},
+	}
+	defer func() {
+		audit.Close()
+		audit = nil
+	}()
+
+	fn(dir)
+}
+
+// readAudit returns all records of the user's audit file.
+func readAudit(t *testing.T, dir string, userID int) []*auditRecord {
+	f, err := os.Open(auditUserFile(dir, userID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var rs []*auditRecord
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var r auditRecord
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		rs = append(rs, &r)
+	}
+	return rs
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	var tests = []struct {
+		Name          string
+		Packet        string
+		Before, After string
+		Error         string
+	}{
+		{"Test01: state change is recorded", `{"type":"rotate","obj":{"angle":"right"}}`, "north", "east", "ok"},
+		{"Test02: failed op keeps the state", `{"type":"rotate","obj":{"angle":"up"}}`, "north", "north", "invalid_packet"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			withAudit(t, func(dir string) {
+				p, err := vbge.NewPlayerWithSpawn(1, vbge.NewMapEntity(vbge.MapWidth, vbge.MapHeight))
+				if !assert.NoError(err) {
+					return
+				}
+				p.WatchDir = "north"
+
+				c := newTestClient("10.0.5.1")
+				c.UserID = 1
+				c.Authenticated = true
+				c.Player = p
+				c.send(tt.Packet)
+
+				rs := readAudit(t, dir, 1)
+				if !assert.Len(rs, 1) {
+					return
+				}
+				r := rs[0]
+				assert.Equal(1, r.UserID)
+				assert.Equal("rotate", r.Op)
+				assert.Equal("ntcp", r.Transport)
+				assert.JSONEq(tt.Packet, string(r.Request))
+				if assert.NotNil(r.Before) && assert.NotNil(r.After) {
+					assert.Equal(tt.Before, r.Before.WatchDir)
+					assert.Equal(tt.After, r.After.WatchDir)
+					assert.Equal(*p.Location, r.After.Location)
+				}
+				assert.Len(r.Responses, 1)
+				assert.Equal(tt.Error, replayOutcome(r))
+			})
+		})
+	}
+}
+
+func TestAuditMiddlewareSkipsUnauthenticated(t *testing.T) {
+	assert := assert.New(t)
+
+	withAudit(t, func(dir string) {
+		c := newTestClient("10.0.5.2")
+		c.UserID = 2
+		c.send(`{"type":"ping","obj":{}}`)
+
+		_, err := os.Stat(auditUserFile(dir, 2))
+		assert.True(os.IsNotExist(err))
+	})
+}
+
+func TestAuditQueryMatch(t *testing.T) {
+	at := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	r := &auditRecord{Time: at, UserID: 4, Op: "move"}
+
+	var tests = []struct {
+		Name  string
+		Query auditQuery
+		Match bool
+	}{
+		{"Test01: empty query", auditQuery{}, true},
+		{"Test02: user", auditQuery{UserID: 4}, true},
+		{"Test03: other user", auditQuery{UserID: 5}, false},
+		{"Test04: op", auditQuery{Op: "move"}, true},
+		{"Test05: other op", auditQuery{Op: "rotate"}, false},
+		{"Test06: since before", auditQuery{Since: at.Add(-time.Second)}, true},
+		{"Test07: since equal", auditQuery{Since: at}, true},
+		{"Test08: since after", auditQuery{Since: at.Add(time.Nanosecond)}, false},
+		{"Test09: until after", auditQuery{Until: at.Add(time.Second)}, true},
+		{"Test10: until equal", auditQuery{Until: at}, true},
+		{"Test11: until before", auditQuery{Until: at.Add(-time.Nanosecond)}, false},
+		{"Test12: all filters", auditQuery{UserID: 4, Op: "move", Since: at, Until: at}, true},
+		{"Test13: one filter fails", auditQuery{UserID: 4, Op: "attack", Since: at, Until: at}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Match, tt.Query.Match(r))
+		})
+	}
+}
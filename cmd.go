@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command is a subcommand of the vbgs binary (e.g. `vbgs map validate`).
+// Commands register themselves during init through registerCommand.
+type command struct {
+	// Name of the command. Nested commands are separated by a space
+	Name string
+	// Usage is a one line description shown in the command overview
+	Usage string
+	Run   func(args []string)
+}
+
+var commands = map[string]*command{}
+
+func registerCommand(c *command) {
+	if _, exists := commands[c.Name]; exists {
+		panic("command " + c.Name + " registered twice")
+	}
+	commands[c.Name] = c
+}
+
+func init() {
+	registerCommand(&command{
+		Name:  "version",
+		Usage: "print the version of vbgs",
+		Run:   versionCmd,
+	})
+}
+
+// runCommand executes the command named by the first (or first two)
+// arguments. Without a command the server is started, so `vbgs -config`
+// keeps working.
+func runCommand(args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		commands["serve"].Run(args)
+		return
+	}
+
+	if args[0] == "help" {
+		commandUsage()
+		return
+	}
+
+	if len(args) > 1 {
+		if c, ok := commands[args[0]+" "+args[1]]; ok {
+			c.Run(args[2:])
+			return
+		}
+	}
+	if c, ok := commands[args[0]]; ok {
+		c.Run(args[1:])
+		return
+	}
+
+	commandUsage()
+	os.Exit(2)
+}
+
+func commandUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "usage: vbgs <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, commands[name].Usage)
+	}
+}
+
+// newCommandFlags returns the flag set of a command.
+func newCommandFlags(name string) *flag.FlagSet {
+	return flag.NewFlagSet("vbgs "+name, flag.ExitOnError)
+}
+
+// configFlag adds the -config flag shared by all commands to fs.
+func configFlag(fs *flag.FlagSet) *string {
+	return fs.String("config", "", "path to config file")
+}
+
+// cliSetup loads the config and initializes the logging of tool commands.
+// Tools log to stderr, so their output on stdout stays usable, and neither
+// write into the server's log file nor report to sentry. Unlike the server
+// tools don't require a complete config (e.g. database and listeners). An
+// empty path uses the defaults.
+func cliSetup(path string) {
+	conf, problems := parseConfig(path, os.LookupEnv)
+	exitOnConfigProblems(path, problems)
+	conf.Log.File.Active = false
+	conf.Log.Sentry.Active = false
+	setConfig(conf)
+
+	logOutput = os.Stderr
+	initLog()
+}
+
+func versionCmd(args []string) {
+	v := Version
+	if v == "" {
+		v = "dev"
+	}
+	fmt.Println("vikebot/vbgs@" + v)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	logSimple "log"
+	"os"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		Name:  "audit",
+		Usage: "search the audit log of a round",
+		Run:   auditCmd,
+	})
+}
+
+// auditFlags are the flags of all commands reading the audit log.
+type auditFlags struct {
+	conf    *string
+	dir     *string
+	roundID *int
+	userID  *int
+	op      *string
+	since   *string
+	until   *string
+}
+
+func newAuditFlags(fs *flag.FlagSet) *auditFlags {
+	return &auditFlags{
+		conf:    fs.String("config", "", "path to config file (provides audit directory and round)"),
+		dir:     fs.String("dir", "", "audit directory (overrides config)"),
+		roundID: fs.Int("round", 0, "round id (overrides config)"),
+		userID:  fs.Int("user", 0, "only show records of this user"),
+		op:      fs.String("op", "", "only show records of this op"),
+		since:   fs.String("since", "", "only show records at or after this time (RFC3339)"),
+		until:   fs.String("until", "", "only show records at or before this time (RFC3339)"),
+	}
+}
+
+// resolve returns the audit directory of the round and the query described
+// by the flags. Invalid flags end the process.
+func (f *auditFlags) resolve() (string, auditQuery) {
+	if *f.conf != "" {
+		cliSetup(*f.conf)
+		if *f.dir == "" {
+			*f.dir = config().Log.Audit.Dir
+		}
+		if *f.roundID == 0 {
+			*f.roundID = config().Battle.RoundID
+		}
+	}
+	if *f.dir == "" || *f.roundID == 0 {
+		logSimple.Fatal("audit directory and round required. use -config or -dir and -round")
+	}
+
+	q := auditQuery{
+		UserID: *f.userID,
+		Op:     *f.op,
+	}
+	var err error
+	if *f.since != "" {
+		q.Since, err = time.Parse(time.RFC3339, *f.since)
+		if err != nil {
+			logSimple.Fatal("invalid -since: " + err.Error())
+		}
+	}
+	if *f.until != "" {
+		q.Until, err = time.Parse(time.RFC3339, *f.until)
+		if err != nil {
+			logSimple.Fatal("invalid -until: " + err.Error())
+		}
+	}
+	return auditRoundDir(*f.dir, *f.roundID), q
+}
+
+// auditCmd implements `vbgs audit`. It prints all audit records of a round
+// matching the filters as JSON lines.
+func auditCmd(args []string) {
+	fs := newCommandFlags("audit")
+	flags := newAuditFlags(fs)
+	_ = fs.Parse(args) /* #nosec G104 */
+
+	dir, q := flags.resolve()
+	err := auditSearch(dir, q, func(line []byte) {
+		fmt.Fprintln(os.Stdout, string(line))
+	})
+	if err != nil {
+		logSimple.Fatal("searching audit log failed: " + err.Error())
+	}
+}
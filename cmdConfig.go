@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	logSimple "log"
+	"os"
+)
+
+func init() {
+	registerCommand(&command{
+		Name:  "config check",
+		Usage: "validate a config file including the environment overrides",
+		Run:   configCheckCmd,
+	})
+}
+
+// configCheckCmd implements `vbgs config check`. It lists all problems of a
+// configfile instead of stopping at the first one.
+func configCheckCmd(args []string) {
+	fs := newCommandFlags("config check")
+	conf := configFlag(fs)
+	_ = fs.Parse(args) /* #nosec G104 */
+
+	if *conf == "" {
+		logSimple.Fatal("no gameserver config defined")
+	}
+
+	_, problems := readConfig(*conf)
+	if len(problems) > 0 {
+		fmt.Println("invalid config " + *conf + ":")
+		for _, p := range problems {
+			fmt.Println("  - " + p)
+		}
+		os.Exit(1)
+	}
+	fmt.Println("config ok")
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	logSimple "log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vikebot/vbgs/pkg/mapimg"
+	"github.com/vikebot/vbgs/vbge"
+)
+
+func init() {
+	registerCommand(&command{
+		Name:  "map validate",
+		Usage: "check map files (defaults to the map of the config)",
+		Run:   mapValidateCmd,
+	})
+	registerCommand(&command{
+		Name:  "map generate",
+		Usage: "generate a map from a seed",
+		Run:   mapGenerateCmd,
+	})
+	registerCommand(&command{
+		Name:  "map import",
+		Usage: "convert a PNG or GIF image into a map",
+		Run:   mapImportCmd,
+	})
+}
+
+// mapValidateCmd implements `vbgs map validate [-config path] [map ...]`.
+func mapValidateCmd(args []string) {
+	fs := newCommandFlags("map validate")
+	conf := configFlag(fs)
+	strict := fs.Bool("strict", false, "fail on unreachable areas")
+	_ = fs.Parse(args) /* #nosec G104 */
+
+	files := fs.Args()
+	if len(files) == 0 {
+		if *conf == "" {
+			logSimple.Fatal("no map defined. pass map files or use -config")
+		}
+		cliSetup(*conf)
+		files = []string{config().Battle.Map}
+	}
+
+	failed := false
+	for _, file := range files {
+		m, problems := checkMap(file)
+		if len(problems) > 0 {
+			failed = true
+			fmt.Println("invalid map " + file + ":")
+			for _, p := range problems {
+				fmt.Println("  - " + p)
+			}
+			continue
+		}
+
+		blocks := m.Blocks
+		a := vbge.AnalyzeMap(blocks)
+		warnings := a.Warnings()
+		if len(warnings) > 0 {
+			failed = failed || *strict
+			fmt.Printf("%s: %d of %d walkable blocks unreachable (%dx%d)\n", file, a.Unreachable(), a.Walkable, len(blocks[0]), len(blocks))
+			for _, w := range warnings {
+				fmt.Println("  - " + w)
+			}
+			continue
+		}
+		fmt.Printf("%s: ok (%dx%d, %d spawn points, %d spawn zones)\n", file, len(blocks[0]), len(blocks), len(m.Spawns), len(m.Zones))
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// mapGenerateCmd implements `vbgs map generate`. The options default to
+// `battle.generate` of -config.
+func mapGenerateCmd(args []string) {
+	fs := newCommandFlags("map generate")
+	conf := configFlag(fs)
+	out := fs.String("o", "map.json", "output file")
+	preview := fs.String("png", "", "also render the map into this PNG file")
+	seed := fs.Int64("seed", 0, "seed of the map (0 picks one)")
+	width := fs.Int("width", 0, "width of the map")
+	height := fs.Int("height", 0, "height of the map")
+	water := fs.Float64("water", -1, "share of water blocks (0 to 0.6)")
+	lava := fs.Int("lava", -1, "number of lava pockets")
+	biomes := fs.String("biomes", "", "comma separated walkable blocktypes (e.g. grass,dirt)")
+	symmetry := fs.String("symmetry", "", "none, mirror or rotational")
+	_ = fs.Parse(args) /* #nosec G104 */
+
+	opt := defaultConfig().Battle.Generate
+	if *conf != "" {
+		cliSetup(*conf)
+		opt = config().Battle.Generate
+	}
+	if *seed != 0 {
+		opt.Seed = *seed
+	}
+	if *width > 0 {
+		opt.Width = *width
+	}
+	if *height > 0 {
+		opt.Height = *height
+	}
+	if *water >= 0 {
+		opt.WaterRatio = *water
+	}
+	if *lava >= 0 {
+		opt.LavaPockets = *lava
+	}
+	if *biomes != "" {
+		opt.Biomes = strings.Split(*biomes, ",")
+	}
+	if *symmetry != "" {
+		opt.Symmetry = *symmetry
+	}
+	if opt.Seed == 0 {
+		opt.Seed = time.Now().UnixNano()
+	}
+
+	blocks, err := vbge.GenerateMap(opt)
+	if err != nil {
+		logSimple.Fatal(err.Error())
+	}
+	err = saveMap(*out, &vbge.MapFile{Blocks: blocks})
+	if err != nil {
+		logSimple.Fatal("unable to write map: " + err.Error())
+	}
+	fmt.Fprintf(os.Stderr, "generated %s (%dx%d) with seed %d\n", *out, opt.Width, opt.Height, opt.Seed)
+
+	if *preview != "" {
+		f, err := os.Create(*preview)
+		if err != nil {
+			logSimple.Fatal("unable to create preview: " + err.Error())
+		}
+		defer f.Close()
+
+		err = mapimg.Encode(f, mapimg.Render(blocks, mapimg.Options{}))
+		if err != nil {
+			logSimple.Fatal("unable to encode png: " + err.Error())
+		}
+	}
+}
+
+// mapImportCmd implements `vbgs map import image.png`. Every tile of the image
+// becomes the blocktype with the nearest color of the palette.
+func mapImportCmd(args []string) {
+	fs := newCommandFlags("map import")
+	out := fs.String("o", "map.json", "output file")
+	palettePath := fs.String("palette", "", "JSON object of blocktypes and hex colors (defaults to the render colors)")
+	tileSize := fs.Int("tile", 1, "size of a block in pixel")
+	width := fs.Int("width", 0, "expected width of the map in blocks")
+	height := fs.Int("height", 0, "expected height of the map in blocks")
+	_ = fs.Parse(args) /* #nosec G104 */
+
+	if fs.NArg() != 1 {
+		logSimple.Fatal("usage: vbgs map import [flags] <image>")
+	}
+
+	opt := mapimg.ImportOptions{
+		TileSize: *tileSize,
+		Width:    *width,
+		Height:   *height,
+	}
+	if *palettePath != "" {
+		data, err := ioutil.ReadFile(*palettePath)
+		if err != nil {
+			logSimple.Fatal("unable to read palette: " + err.Error())
+		}
+		err = json.Unmarshal(data, &opt.Palette)
+		if err != nil {
+			logSimple.Fatal("invalid palette: " + err.Error())
+		}
+		for bt := range opt.Palette {
+			if !vbge.IsBlocktype(bt) {
+				logSimple.Fatal("invalid palette: unknown blocktype " + bt)
+			}
+		}
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		logSimple.Fatal("unable to open image: " + err.Error())
+	}
+	defer f.Close()
+
+	img, err := mapimg.Decode(f, *tileSize)
+	if err != nil {
+		logSimple.Fatal("unable to decode image: " + err.Error())
+	}
+	blocks, err := mapimg.Import(img, opt)
+	if err != nil {
+		logSimple.Fatal(err.Error())
+	}
+	if problems := vbge.ValidateMap(blocks); len(problems) > 0 {
+		logSimple.Fatal("imported map is invalid: " + summarizeProblems(problems))
+	}
+
+	err = saveMap(*out, &vbge.MapFile{Blocks: blocks})
+	if err != nil {
+		logSimple.Fatal("unable to write map: " + err.Error())
+	}
+	fmt.Fprintf(os.Stderr, "imported %s (%dx%d)\n", *out, len(blocks[0]), len(blocks))
+}
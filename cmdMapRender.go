@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+	logSimple "log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vikebot/vbgs/pkg/mapimg"
+	"github.com/vikebot/vbgs/vbge"
+)
+
+func init() {
+	registerCommand(&command{
+		Name:  "map render",
+		Usage: "render a map, a replay frame or a live round as PNG",
+		Run:   mapRenderCmd,
+	})
+}
+
+// mapRenderCmd implements `vbgs map render`. Without a source flag the map
+// file (argument or `battle.map` of -config) is rendered. -at adds the players
+// at that time of the round's audit log and -live renders the current state
+// of a running server through its admin interface.
+func mapRenderCmd(args []string) {
+	fs := newCommandFlags("map render")
+	conf := configFlag(fs)
+	out := fs.String("o", "map.png", "output file")
+	tileSize := fs.Int("tile", 8, "size of a block in pixel")
+	grid := fs.Bool("grid", false, "draw the borders of all blocks")
+	spawns := fs.Bool("spawns", false, "mark the spawn points and zones of the map")
+	at := fs.String("at", "", "render the players at this time of the audit log (RFC3339)")
+	auditDir := fs.String("dir", "", "audit directory for -at (overrides config)")
+	roundID := fs.Int("round", 0, "round id for -at (overrides config)")
+	live := fs.String("live", "", "admin address of a running server (e.g. http://localhost:2402)")
+	token := fs.String("token", "", "admin token for -live (defaults to config)")
+	_ = fs.Parse(args) /* #nosec G104 */
+
+	if *conf != "" {
+		cliSetup(*conf)
+	}
+
+	opt := mapimg.Options{
+		TileSize: *tileSize,
+		Grid:     *grid,
+	}
+
+	var blocks [][]string
+	var err error
+	switch {
+	case *live != "":
+		if *token == "" && config() != nil {
+			*token = config().Admin.Token
+		}
+		blocks, opt.Players, err = mapRenderLive(*live, *token)
+		if err != nil {
+			logSimple.Fatal("fetching live map failed: " + err.Error())
+		}
+	default:
+		m := mapRenderMap(fs.Args())
+		blocks = m.Blocks
+		if *spawns {
+			opt.Spawns = mapRenderSpawns(m)
+		}
+		if *at != "" {
+			opt.Players = mapRenderFrame(*at, *auditDir, *roundID)
+		}
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		logSimple.Fatal("unable to create output: " + err.Error())
+	}
+	defer f.Close()
+
+	err = mapimg.Encode(f, mapimg.Render(blocks, opt))
+	if err != nil {
+		logSimple.Fatal("unable to encode png: " + err.Error())
+	}
+}
+
+// mapRenderMap loads the map passed as argument or configured in
+// `battle.map`.
+func mapRenderMap(args []string) *vbge.MapFile {
+	path := ""
+	switch {
+	case len(args) > 0:
+		path = args[0]
+	case config() != nil:
+		path = config().Battle.Map
+	default:
+		logSimple.Fatal("no map defined. pass a map file or use -config")
+	}
+
+	m, problems := checkMap(path)
+	if m == nil {
+		logSimple.Fatal("unable to load map: " + strings.Join(problems, "; "))
+	}
+	// invalid blocks are rendered in a signal color, so the preview helps
+	// finding them
+	return m
+}
+
+// mapRenderSpawns returns the spawn points and all blocks of the spawn zones
+// of m.
+func mapRenderSpawns(m *vbge.MapFile) []image.Point {
+	var spawns []image.Point
+	for _, l := range m.Spawns {
+		spawns = append(spawns, image.Point{X: l.X, Y: l.Y})
+	}
+	for _, z := range m.Zones {
+		for y := z.Y; y < z.Y+z.Height; y++ {
+			for x := z.X; x < z.X+z.Width; x++ {
+				spawns = append(spawns, image.Point{X: x, Y: y})
+			}
+		}
+	}
+	return spawns
+}
+
+// mapRenderFrame returns the players at the time at. Each player is placed
+// where its last op before at left it.
+func mapRenderFrame(at, dir string, roundID int) []mapimg.Player {
+	t, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		logSimple.Fatal("invalid -at: " + err.Error())
+	}
+	if config() != nil {
+		if dir == "" {
+			dir = config().Log.Audit.Dir
+		}
+		if roundID == 0 {
+			roundID = config().Battle.RoundID
+		}
+	}
+	if dir == "" || roundID == 0 {
+		logSimple.Fatal("audit directory and round required. use -config or -dir and -round")
+	}
+
+	records, err := replayLoad(auditRoundDir(dir, roundID), auditQuery{Until: t})
+	if err != nil {
+		logSimple.Fatal("reading audit log failed: " + err.Error())
+	}
+
+	// records are sorted, so later records overwrite earlier ones
+	last := map[int]*auditPlayer{}
+	for _, r := range records {
+		if r.After != nil {
+			last[r.UserID] = r.After
+		}
+	}
+
+	players := make([]mapimg.Player, 0, len(last))
+	for userID, p := range last {
+		players = append(players, mapimg.Player{
+			ID:       strconv.Itoa(userID),
+			X:        p.Location.X,
+			Y:        p.Location.Y,
+			WatchDir: p.WatchDir,
+		})
+	}
+	return players
+}
+
+// mapRenderLive fetches the map and all players from the admin interface of
+// a running server.
+func mapRenderLive(addr, token string) ([][]string, []mapimg.Player, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(addr, "/")+adminPrefix+"map", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, errors.New("admin interface responded with " + resp.Status)
+	}
+
+	var m adminMapResponse
+	err = json.NewDecoder(resp.Body).Decode(&m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	players := make([]mapimg.Player, len(m.Players))
+	for i, p := range m.Players {
+		players[i] = mapimg.Player{
+			ID: p.GRID,
+			X:  p.Location.X,
+			Y:  p.Location.Y,
+		}
+	}
+	return m.Blocks, players, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	logSimple "log"
+	"os"
+	"sort"
+	"time"
+)
+
+func init() {
+	registerCommand(&command{
+		Name:  "replay",
+		Usage: "replay the audit log of a round in chronological order",
+		Run:   replayCmd,
+	})
+}
+
+// replayCmd implements `vbgs replay`. It merges the audit records of all
+// users and prints them in the order they happened. With -speed the original
+// timing is reproduced (e.g. 2 replays twice as fast).
+func replayCmd(args []string) {
+	fs := newCommandFlags("replay")
+	flags := newAuditFlags(fs)
+	speed := fs.Float64("speed", 0, "replay speed relative to the round (0 prints without delay)")
+	raw := fs.Bool("json", false, "print the records as JSON lines")
+	_ = fs.Parse(args) /* #nosec G104 */
+
+	dir, q := flags.resolve()
+	records, err := replayLoad(dir, q)
+	if err != nil {
+		logSimple.Fatal("reading audit log failed: " + err.Error())
+	}
+
+	var prev time.Time
+	for _, r := range records {
+		if *speed > 0 && !prev.IsZero() {
+			time.Sleep(time.Duration(float64(r.Time.Sub(prev)) / *speed))
+		}
+		prev = r.Time
+
+		if *raw {
+			buf, _ := json.Marshal(r)
+			fmt.Fprintln(os.Stdout, string(buf))
+			continue
+		}
+		fmt.Fprintln(os.Stdout, replayLine(r))
+	}
+}
+
+// replayLoad returns all records of the round matching q sorted by time.
+func replayLoad(dir string, q auditQuery) ([]*auditRecord, error) {
+	records := []*auditRecord{}
+	var decodeErr error
+	err := auditSearch(dir, q, func(line []byte) {
+		var r auditRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			decodeErr = err
+			return
+		}
+		records = append(records, &r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+	return records, nil
+}
+
+// replayOutcome returns "ok" or the error names of the record's responses.
+func replayOutcome(r *auditRecord) string {
+	outcome := "ok"
+	for _, buf := range r.Responses {
+		var res struct {
+			ErrorInfo *responseError `json:"errorinfo"`
+		}
+		if json.Unmarshal(buf, &res) == nil && res.ErrorInfo != nil {
+			outcome = res.ErrorInfo.Name
+		}
+	}
+	return outcome
+}
+
+// replayLine formats a record as human readable line.
+func replayLine(r *auditRecord) string {
+	line := fmt.Sprintf("%s user=%d op=%s outcome=%s latency=%.1fms",
+		r.Time.Format("15:04:05.000"), r.UserID, r.Op, replayOutcome(r), r.Latency)
+
+	if r.Before != nil && r.After != nil {
+		line += fmt.Sprintf(" loc=(%d,%d)", r.Before.Location.X, r.Before.Location.Y)
+		if r.Before.Location != r.After.Location {
+			line += fmt.Sprintf("->(%d,%d)", r.After.Location.X, r.After.Location.Y)
+		}
+		line += fmt.Sprintf(" health=%d", r.Before.Health)
+		if r.Before.Health != r.After.Health {
+			line += fmt.Sprintf("->%d", r.After.Health)
+		}
+		if r.Before.Kills != r.After.Kills {
+			line += fmt.Sprintf(" kills=%d", r.After.Kills)
+		}
+		if r.Before.Deaths != r.After.Deaths {
+			line += fmt.Sprintf(" deaths=%d", r.After.Deaths)
+		}
+	}
+	return line
+}
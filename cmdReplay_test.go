@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vikebot/vbgs/vbge"
+)
+
+func TestReplayLoad(t *testing.T) {
+	var tests = []struct {
+		Name    string
+		Files   map[int]string
+		Query   auditQuery
+		Records []string
+		Err     bool
+	}{
+		{"Test01: users are merged in time order",
+			map[int]string{
+				1: `{"time":"2026-10-19T12:00:01Z","user_id":1,"op":"move"}` + "\n" +
+					`{"time":"2026-10-19T12:00:04Z","user_id":1,"op":"attack"}` + "\n",
+				2: `{"time":"2026-10-19T12:00:02Z","user_id":2,"op":"rotate"}` + "\n" +
+					`{"time":"2026-10-19T12:00:03Z","user_id":2,"op":"scout"}` + "\n",
+			},
+			auditQuery{},
+			[]string{"1:move", "2:rotate", "2:scout", "1:attack"}, false},
+		{"Test02: query filters the records",
+			map[int]string{
+				1: `{"time":"2026-10-19T12:00:01Z","user_id":1,"op":"move"}` + "\n",
+				2: `{"time":"2026-10-19T12:00:02Z","user_id":2,"op":"move"}` + "\n" +
+					`{"time":"2026-10-19T12:00:03Z","user_id":2,"op":"scout"}` + "\n",
+			},
+			auditQuery{UserID: 2, Op: "move"},
+			[]string{"2:move"}, false},
+		{"Test03: corrupt line",
+			map[int]string{
+				1: `{"time":"2026-10-19T12:00:01Z","user_id":1,"op":"move"}` + "\n",
+				2: `{"time":"2026-10-19T12:00:02Z","user_id":2,` + "\n",
+			},
+			auditQuery{},
+			nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			dir, err := ioutil.TempDir("", "vbgs-replay")
+			if !assert.NoError(err) {
+				return
+			}
+			defer os.RemoveAll(dir)
+
+			for userID, content := range tt.Files {
+				assert.NoError(ioutil.WriteFile(auditUserFile(dir, userID), []byte(content), 0644))
+			}
+
+			records, err := replayLoad(dir, tt.Query)
+			if tt.Err {
+				assert.Error(err)
+				return
+			}
+			if !assert.NoError(err) {
+				return
+			}
+
+			var got []string
+			for _, r := range records {
+				got = append(got, strconv.Itoa(r.UserID)+":"+r.Op)
+			}
+			assert.Equal(tt.Records, got)
+		})
+	}
+}
+
+func TestReplayLine(t *testing.T) {
+	at := time.Date(2026, 10, 19, 12, 0, 1, 0, time.UTC)
+	before := &auditPlayer{Location: vbge.Location{X: 1, Y: 2}, Health: 100}
+
+	var tests = []struct {
+		Name   string
+		Record *auditRecord
+		Line   string
+	}{
+		{"Test01: without player", &auditRecord{Time: at, UserID: 1, Op: "ping", Latency: 0.5},
+			"12:00:01.000 user=1 op=ping outcome=ok latency=0.5ms"},
+		{"Test02: unchanged player", &auditRecord{Time: at, UserID: 1, Op: "rotate", Before: before, After: before},
+			"12:00:01.000 user=1 op=rotate outcome=ok latency=0.0ms loc=(1,2) health=100"},
+		{"Test03: changed player", &auditRecord{Time: at, UserID: 1, Op: "move", Before: before,
+			After: &auditPlayer{Location: vbge.Location{X: 1, Y: 3}, Health: 90, Kills: 1}},
+			"12:00:01.000 user=1 op=move outcome=ok latency=0.0ms loc=(1,2)->(1,3) health=100->90 kills=1"},
+		{"Test04: error outcome", &auditRecord{Time: at, UserID: 1, Op: "move",
+			Responses: []json.RawMessage{json.RawMessage(`{"type":"move","errorinfo":{"code":3004,"name":"has_resident"}}`)}},
+			"12:00:01.000 user=1 op=move outcome=has_resident latency=0.0ms"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Line, replayLine(tt.Record))
+		})
+	}
+}
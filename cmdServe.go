@@ -0,0 +1,121 @@
+package main
+
+import (
+	logSimple "log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func init() {
+	registerCommand(&command{
+		Name:  "serve",
+		Usage: "run the gameserver (default if no command is given)",
+		Run:   serveCmd,
+	})
+}
+
+func serveCmd(args []string) {
+	fs := newCommandFlags("serve")
+	conf := configFlag(fs)
+	version := fs.Bool("version", false, "only display the version of vbgs")
+	_ = fs.Parse(args) /* #nosec G104 */
+
+	// Only print version
+	if *version {
+		versionCmd(nil)
+		return
+	}
+
+	if *conf == "" {
+		logSimple.Fatal("no gameserver config defined")
+	}
+	setConfig(loadConfig(*conf))
+
+	// init zap logging
+	initLog()
+	defer log.Sync()
+
+	// print version
+	if len(Version) != 0 {
+		log.Info("running vbgs at version", zap.String("version", Version))
+	}
+
+	// Prepare basic stuff of the server and init our battle (fetch map)
+	gsInit()
+
+	// getAllPlayers
+	joinedPlayers := getJoinedPlayers()
+
+	// init the battle
+	battleInit(joinedPlayers)
+
+	// init the distributor
+	distributorInit(joinedPlayers)
+	metricsInit()
+
+	// Start and shutdown channels
+	startChan := make(chan bool)
+	shutdownChan := make(chan bool)
+
+	// Start the network services
+	ntcpInit(startChan, shutdownChan)
+	nwsInit(startChan, shutdownChan)
+	nhttpInit(startChan, shutdownChan)
+	adminInit(startChan, shutdownChan)
+
+	// Sleep till start
+	startTime := time.Now().UTC().Add(time.Second * 2)
+	sleepDuration := startTime.Sub(time.Now().UTC())
+	log.Info("prepared services. sleeping till starttime",
+		zap.Time("starttime", startTime),
+		zap.Duration("sleeping", sleepDuration))
+	time.Sleep(sleepDuration)
+
+	// Activate services that listen on starting channel signal
+	close(startChan)
+
+	// Shutdown services as soon as the round is over or we are told to stop
+	round.Start(config().Battle.Duration.Duration)
+	if config().Battle.Duration.Duration > 0 {
+		log.Info("started services. sleeping till shutdown",
+			zap.Time("shutdowntime", time.Now().UTC().Add(config().Battle.Duration.Duration)))
+	} else {
+		log.Info("started services. round runs till it's ended")
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	for {
+		select {
+		case reason := <-round.Done():
+			shutdown(reason, shutdownChan)
+			return
+		case sig := <-signals:
+			log.Info("received signal", zap.String("signal", sig.String()))
+			if sig == syscall.SIGHUP {
+				err := reloadConfig(*conf)
+				if err != nil {
+					log.Error("reloading config failed", zap.Error(err))
+					continue
+				}
+				log.Info("reloaded config")
+
+				_, err = rulesReload()
+				if err != nil {
+					log.Error("reloading rules failed", zap.Error(err))
+				}
+				continue
+			}
+
+			err := round.End("The server is shutting down")
+			if err != nil {
+				log.Warn("ending round failed", zap.Error(err))
+			}
+		}
+	}
+}
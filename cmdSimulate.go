@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	logSimple "log"
+	"math/rand"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vikebot/vbgs/vbge"
+	"go.uber.org/zap"
+)
+
+func init() {
+	registerCommand(&command{
+		Name:  "simulate",
+		Usage: "play a round with simple bots offline to test maps and rules",
+		Run:   simulateCmd,
+	})
+}
+
+// simulateCmd implements `vbgs simulate`. It plays a round on the configured
+// map and rules with bots that attack whenever an enemy stands in front of
+// them and otherwise move or rotate randomly. Neither the database nor the
+// network is used and throttles are ignored, so a round takes only seconds.
+func simulateCmd(args []string) {
+	fs := newCommandFlags("simulate")
+	conf := configFlag(fs)
+	mapPath := fs.String("map", "", "map file (overrides config)")
+	bots := fs.Int("bots", 8, "number of bots")
+	ticks := fs.Int("ticks", 1000, "number of ops every bot executes")
+	seed := fs.Int64("seed", 0, "seed of the simulation (0 picks one)")
+	_ = fs.Parse(args) /* #nosec G104 */
+
+	cliSetup(*conf)
+	if *bots < 1 || *ticks < 1 {
+		logSimple.Fatal("-bots and -ticks must be positive")
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	rand.Seed(*seed)
+
+	next := *config()
+	if *mapPath != "" {
+		next.Battle.Map = *mapPath
+	}
+	if next.Battle.Generate.Seed == 0 {
+		next.Battle.Generate.Seed = *seed
+	}
+	setConfig(&next)
+
+	rulesInit()
+
+	joined := make([]int, *bots)
+	for i := range joined {
+		joined[i] = i + 1
+	}
+	battleInit(joined)
+
+	start := time.Now()
+	for t := 0; t < *ticks; t++ {
+		for _, userID := range joined {
+			simulateOp(battle.Players[userID])
+		}
+	}
+	log.Info("simulation finished",
+		zap.Int64("seed", *seed),
+		zap.Duration("took", time.Since(start)))
+
+	simulateReport(joined)
+}
+
+// simulateOp lets p execute a single op.
+func simulateOp(p *vbge.Player) {
+	_, _, err := p.Attack(
+		func(*vbge.Player, int, vbge.NotifyGroupLocated) {},
+		func(*vbge.Player, vbge.NotifyGroupLocated) {},
+		func(*vbge.Player, vbge.NotifyGroupLocated) error { return nil },
+		func([]vbge.Player) {})
+	if err == nil {
+		return
+	}
+	if err != vbge.ErrNoEnemy && err != vbge.ErrOutOfMap {
+		log.Warn("attack failed", zap.Int("user_id", p.UserID), zap.Error(err))
+		return
+	}
+
+	/* #nosec G404 */
+	if rand.Intn(4) == 0 {
+		angle := "left"
+		if rand.Intn(2) == 0 {
+			angle = "right"
+		}
+		p.Rotate(angle)
+		return
+	}
+	// moving fails at the border or into blocked blocks. the bot rotates
+	// next time
+	_, _ = p.Move(p.WatchDir)
+}
+
+func simulateReport(joined []int) {
+	sort.Slice(joined, func(i, j int) bool {
+		a, b := battle.Players[joined[i]], battle.Players[joined[j]]
+		if a.Kills != b.Kills {
+			return a.Kills > b.Kills
+		}
+		return a.Deaths < b.Deaths
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "user\tkills\tdeaths\thealth\tlocation")
+	kills := 0
+	for _, userID := range joined {
+		p := battle.Players[userID]
+		kills += p.Kills
+		fmt.Fprintf(w, "%d\t%d\t%d\t%d\t(%d,%d)\n", p.UserID, p.Kills, p.Deaths, p.Health.HealthSynced(), p.Location.X, p.Location.Y)
+	}
+	_ = w.Flush() /* #nosec G104 */
+	fmt.Printf("\n%d kills in total\n", kills)
+}
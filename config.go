@@ -2,10 +2,17 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/vikebot/vbgs/vbge"
 	"go.uber.org/zap/zapcore"
 )
 
@@ -13,16 +20,24 @@ type gameserverConfig struct {
 	Instance string `json:"instance"`
 
 	Log struct {
-		Level   zapcore.Level `json:"level"`
-		Config  string        `json:"config"`
-		Colored bool          `json:"colored"`
+		Level   logLevel `json:"level"`
+		Config  string   `json:"config"`
+		Colored bool     `json:"colored"`
 		File    struct {
-			Active bool `json:"active"`
+			Active     bool   `json:"active"`
+			Path       string `json:"path"`
+			MaxSize    int    `json:"max_size"`
+			MaxAge     int    `json:"max_age"`
+			MaxBackups int    `json:"max_backups"`
 		} `json:"file"`
 		Sentry struct {
-			Active bool   `json:"active"`
-			DSN    string `json:"dsn"`
+			Active      bool   `json:"active"`
+			DSN         string `json:"dsn"`
+			Environment string `json:"environment"`
 		} `json:"sentry"`
+		Audit struct {
+			Dir string `json:"dir"`
+		} `json:"audit"`
 	} `json:"log"`
 
 	Database struct {
@@ -36,12 +51,34 @@ type gameserverConfig struct {
 
 	Network struct {
 		TCP struct {
-			Addr string `json:"addr"`
+			Addr     string `json:"addr"`
+			Timeouts struct {
+				Read      duration `json:"read"`
+				Write     duration `json:"write"`
+				Keepalive duration `json:"keepalive"`
+				Handshake duration `json:"handshake"`
+			} `json:"timeouts"`
+			Limits struct {
+				MaxPacketSize int `json:"max_packet_size"`
+				MaxConnsPerIP int `json:"max_conns_per_ip"`
+				Ban           struct {
+					Violations int      `json:"violations"`
+					Window     duration `json:"window"`
+					Duration   duration `json:"duration"`
+				} `json:"ban"`
+			} `json:"limits"`
 		} `json:"tcp"`
 		WS struct {
 			Addr        string `json:"addr"`
 			ValidOrigin string `json:"valid_origin"`
-			TLS         struct {
+			Timeouts    struct {
+				Ping         duration `json:"ping"`
+				Pong         duration `json:"pong"`
+				Write        duration `json:"write"`
+				SSEKeepalive duration `json:"sse_keepalive"`
+			} `json:"timeouts"`
+			SnapshotInterval duration `json:"snapshot_interval"`
+			TLS              struct {
 				Active bool   `json:"active"`
 				Cert   string `json:"cert"`
 				PKey   string `json:"pkey"`
@@ -51,29 +88,413 @@ type gameserverConfig struct {
 				Stats bool `json:"stats"`
 			} `json:"flags"`
 		} `json:"ws"`
+		HTTP struct {
+			Addr string `json:"addr"`
+			TLS  struct {
+				Active bool   `json:"active"`
+				Cert   string `json:"cert"`
+				PKey   string `json:"pkey"`
+			} `json:"tls"`
+		} `json:"http"`
 	} `json:"network"`
 
+	Admin struct {
+		Addr  string `json:"addr"`
+		Token string `json:"token"`
+		TLS   struct {
+			Active bool   `json:"active"`
+			Cert   string `json:"cert"`
+			PKey   string `json:"pkey"`
+		} `json:"tls"`
+	} `json:"admin"`
+
 	Battle struct {
-		RoundID          int    `json:"round_id"`
-		AvatarPictureURL string `json:"avatar_picture_url"`
+		RoundID          int      `json:"round_id"`
+		AvatarPictureURL string   `json:"avatar_picture_url"`
+		Duration         duration `json:"duration"`
+		ShutdownTimeout  duration `json:"shutdown_timeout"`
+		ResultsDir       string   `json:"results_dir"`
+		Rules            string   `json:"rules"`
+		Map              string   `json:"map"`
+		// RejectUnreachable rejects maps with walkable blocks that can't be
+		// reached from the main region instead of only warning
+		RejectUnreachable bool `json:"reject_unreachable"`
+		// SpawnStrategy is random, farthest, team or round_robin
+		SpawnStrategy string `json:"spawn_strategy"`
+		// Teams maps team names (see the zones of the map) to user ids
+		Teams map[string][]int `json:"teams"`
+		// Generate configures the map generator if Map is `generate`
+		Generate vbge.GenerateOptions `json:"generate"`
 	} `json:"battle"`
 }
 
-// loadConfig takes a path to a configfile and returns a
-// pointer to a gameserverConfig
-func loadConfig(path string) *gameserverConfig {
-	f, err := ioutil.ReadFile(path) /* #nosec G304 */
+// duration is a time.Duration that is represented as a string (e.g. "30s")
+// inside the config file. A zero duration disables the corresponding feature.
+// Invalid values don't fail the unmarshaling, but are reported by validate.
+type duration struct {
+	time.Duration
+	invalid string
+}
+
+func (d duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
 	if err != nil {
-		fmt.Println("failed to load config: " + err.Error())
-		os.Exit(-1)
+		d.invalid = string(text)
+		return nil
 	}
 
-	conf := &gameserverConfig{}
-	err = json.Unmarshal(f, conf)
+	d.Duration = v
+	d.invalid = ""
+	return nil
+}
+
+// logLevel is a zapcore.Level represented as string (e.g. "INFO"). Like
+// duration invalid values are reported by validate.
+type logLevel struct {
+	zapcore.Level
+	invalid string
+}
+
+func (l logLevel) MarshalText() ([]byte, error) {
+	return l.Level.MarshalText()
+}
+
+func (l *logLevel) UnmarshalText(text []byte) error {
+	var lvl zapcore.Level
+	err := lvl.UnmarshalText(text)
 	if err != nil {
-		fmt.Println("failed to unmarshal config file: " + err.Error())
-		os.Exit(-1)
+		l.invalid = string(text)
+		return nil
 	}
 
+	l.Level = lvl
+	l.invalid = ""
+	return nil
+}
+
+// defaultConfig returns the config all values of the configfile are applied
+// to. Settings missing in the configfile keep these defaults.
+func defaultConfig() *gameserverConfig {
+	c := &gameserverConfig{}
+
+	c.Log.Level.Level = zapcore.InfoLevel
+	c.Log.Config = "production"
+	c.Log.File.Path = "logs/vbgs.log"
+	c.Log.File.MaxSize = 100 // megabytes
+	c.Log.File.MaxAge = 14   // days
+	c.Log.File.MaxBackups = 10
+
+	// Read timeouts, per-IP connection limits and bans are off by default.
+	// Older SDKs don't send pings and whole classrooms often share a single
+	// NAT address
+	c.Network.TCP.Timeouts.Write.Duration = 10 * time.Second
+	c.Network.TCP.Timeouts.Keepalive.Duration = 30 * time.Second
+	c.Network.TCP.Timeouts.Handshake.Duration = 10 * time.Second
+	c.Network.TCP.Limits.MaxPacketSize = 64 * 1024
+	c.Network.TCP.Limits.Ban.Window.Duration = time.Minute
+	c.Network.TCP.Limits.Ban.Duration.Duration = 10 * time.Minute
+
+	c.Network.WS.Timeouts.Ping.Duration = 30 * time.Second
+	c.Network.WS.Timeouts.Pong.Duration = time.Minute
+	c.Network.WS.Timeouts.Write.Duration = 10 * time.Second
+	c.Network.WS.Timeouts.SSEKeepalive.Duration = 30 * time.Second
+	c.Network.WS.SnapshotInterval.Duration = defaultSnapshotInterval
+	c.Network.WS.Flags.Stats = true
+
+	c.Battle.Duration.Duration = time.Hour
+	c.Battle.ShutdownTimeout.Duration = 30 * time.Second
+	c.Battle.Map = "config/map/map.json"
+	c.Battle.SpawnStrategy = "random"
+	c.Battle.Generate.Width = 101
+	c.Battle.Generate.Height = 101
+	c.Battle.Generate.WaterRatio = 0.2
+
+	return c
+}
+
+// loadConfig takes a path to a configfile and returns a
+// pointer to a gameserverConfig. If the config is invalid all problems are
+// printed and the process exits.
+func loadConfig(path string) *gameserverConfig {
+	conf, problems := readConfig(path)
+	exitOnConfigProblems(path, problems)
+
 	return conf
 }
+
+// exitOnConfigProblems prints all problems of the config and exits the
+// process if there are any.
+func exitOnConfigProblems(path string, problems []string) {
+	if len(problems) == 0 {
+		return
+	}
+
+	fmt.Println("invalid config " + path + ":")
+	for _, p := range problems {
+		fmt.Println("  - " + p)
+	}
+	os.Exit(-1)
+}
+
+// readConfig applies the configfile and afterwards the environment overrides
+// to the defaults. The result is validated and all found problems returned.
+func readConfig(path string) (*gameserverConfig, []string) {
+	conf, problems := parseConfig(path, os.LookupEnv)
+	if conf == nil {
+		return nil, problems
+	}
+
+	problems = append(problems, conf.validate()...)
+	return conf, problems
+}
+
+// parseConfig is like readConfig but doesn't validate the result. An empty
+// path only applies the environment overrides to the defaults. lookup is
+// passed to applyEnv.
+func parseConfig(path string, lookup func(key string) (string, bool)) (*gameserverConfig, []string) {
+	var problems []string
+
+	conf := defaultConfig()
+	if path != "" {
+		f, err := ioutil.ReadFile(path) /* #nosec G304 */
+		if err != nil {
+			return nil, []string{"failed to load config: " + err.Error()}
+		}
+
+		err = json.Unmarshal(f, conf)
+		if err != nil {
+			if _, ok := err.(*json.UnmarshalTypeError); !ok {
+				return nil, []string{"failed to unmarshal config file: " + err.Error()}
+			}
+			// json only reports the first type error. The remaining fields
+			// have been unmarshaled
+			problems = append(problems, configTypeErrors(f)...)
+		}
+	}
+
+	problems = append(problems, applyEnv(conf, lookup)...)
+	return conf, problems
+}
+
+// configTypeErrors returns a problem for every value of the config file buf
+// whose json type doesn't match the config field.
+func configTypeErrors(buf []byte) (problems []string) {
+	var root json.RawMessage = buf
+	reported := map[string]bool{}
+
+	configLeaves(defaultConfig(), func(path []string, f reflect.Value) {
+		raw := root
+		for i, key := range path {
+			var obj map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &obj); err != nil {
+				// report a mistyped object only once instead of for each of
+				// its fields
+				name := strings.Join(path[:i], ".")
+				if !reported[name] {
+					reported[name] = true
+					problems = append(problems, name+": expected an object")
+				}
+				return
+			}
+			if raw = configKey(obj, key); raw == nil {
+				return
+			}
+		}
+
+		err := json.Unmarshal(raw, reflect.New(f.Type()).Interface())
+		if e, ok := err.(*json.UnmarshalTypeError); ok {
+			problems = append(problems, fmt.Sprintf("%s: cannot use %s as %s", strings.Join(path, "."), e.Value, e.Type))
+		}
+	})
+	return problems
+}
+
+// configKey returns the value of key inside obj. Like encoding/json keys are
+// matched case-insensitively if there is no exact match.
+func configKey(obj map[string]json.RawMessage, key string) json.RawMessage {
+	if v, ok := obj[key]; ok {
+		return v
+	}
+	for k, v := range obj {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
+
+// validate checks the config and returns a description of every problem.
+func (c *gameserverConfig) validate() (problems []string) {
+	add := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	configLeaves(c, func(path []string, f reflect.Value) {
+		name := strings.Join(path, ".")
+		switch v := f.Addr().Interface().(type) {
+		case *duration:
+			if v.invalid != "" {
+				add("%s: invalid duration %q", name, v.invalid)
+			} else if v.Duration < 0 {
+				add("%s: mustn't be negative", name)
+			}
+		case *logLevel:
+			if v.invalid != "" {
+				add("%s: unknown level %q", name, v.invalid)
+			}
+		case *int:
+			if *v < 0 {
+				add("%s: mustn't be negative", name)
+			}
+		}
+	})
+
+	switch c.Log.Config {
+	case "development", "dev", "production", "prod":
+	default:
+		add("log.config: unknown type %q. only 'development', 'dev', 'production' and 'prod' are allowed", c.Log.Config)
+	}
+	if c.Log.File.Active && c.Log.File.Path == "" {
+		add("log.file.path: required if log.file.active")
+	}
+	if c.Log.Sentry.Active && c.Log.Sentry.DSN == "" {
+		add("log.sentry.dsn: required if log.sentry.active")
+	}
+
+	if c.Database.MariaDB.Host == "" {
+		add("database.mariadb.host: required")
+	}
+	if c.Database.MariaDB.Name == "" {
+		add("database.mariadb.name: required")
+	}
+
+	if c.Network.TCP.Addr == "" {
+		add("network.tcp.addr: required")
+	}
+	if c.Network.WS.Addr == "" {
+		add("network.ws.addr: required")
+	}
+	if c.Network.WS.ValidOrigin == "" {
+		add("network.ws.valid_origin: required")
+	}
+	if c.Admin.Addr != "" && c.Admin.Token == "" {
+		add("admin.token: required if admin.addr is set")
+	}
+
+	checkTLS := func(name string, active bool, cert, pkey string) {
+		if !active {
+			return
+		}
+		for _, file := range []struct{ field, path string }{{"cert", cert}, {"pkey", pkey}} {
+			if file.path == "" {
+				add("%s.tls.%s: required if %s.tls.active", name, file.field, name)
+				continue
+			}
+			if _, err := os.Stat(file.path); err != nil {
+				add("%s.tls.%s: %v", name, file.field, err)
+			}
+		}
+	}
+	checkTLS("network.ws", c.Network.WS.TLS.Active, c.Network.WS.TLS.Cert, c.Network.WS.TLS.PKey)
+	checkTLS("network.http", c.Network.HTTP.TLS.Active, c.Network.HTTP.TLS.Cert, c.Network.HTTP.TLS.PKey)
+	checkTLS("admin", c.Admin.TLS.Active, c.Admin.TLS.Cert, c.Admin.TLS.PKey)
+
+	if c.Battle.RoundID <= 0 {
+		add("battle.round_id: must be positive")
+	}
+	if c.Battle.Map == "" {
+		add("battle.map: required")
+	} else if c.Battle.Map == mapGenerate {
+		if err := c.Battle.Generate.Validate(); err != nil {
+			add("battle.generate: %v", err)
+		}
+	} else if m, mapProblems := checkMap(c.Battle.Map); len(mapProblems) > 0 {
+		add("battle.map: %s", summarizeProblems(mapProblems))
+	} else if warnings := vbge.AnalyzeMap(m.Blocks).Warnings(); c.Battle.RejectUnreachable && len(warnings) > 0 {
+		add("battle.map: %s", summarizeProblems(warnings))
+	}
+	if _, err := vbge.NewSpawnStrategy(c.Battle.SpawnStrategy, nil); err != nil {
+		add("battle.spawn_strategy: unknown strategy %q", c.Battle.SpawnStrategy)
+	}
+	seen := map[int]string{}
+	for team, userIDs := range c.Battle.Teams {
+		for _, userID := range userIDs {
+			if other, ok := seen[userID]; ok && other != team {
+				add("battle.teams: user %d is member of %s and %s", userID, other, team)
+			}
+			seen[userID] = team
+		}
+	}
+	if c.Battle.Rules != "" {
+		if _, err := readRules(c.Battle.Rules); err != nil {
+			add("battle.rules: %v", err)
+		}
+	}
+
+	return problems
+}
+
+// userTeams inverts the `battle.teams` setting into the team of each user.
+func userTeams(teams map[string][]int) map[int]string {
+	users := map[int]string{}
+	for team, userIDs := range teams {
+		for _, userID := range userIDs {
+			users[userID] = team
+		}
+	}
+	return users
+}
+
+// currentConfig is the config in effect. It's replaced as a whole on every
+// reload, so it must only be accessed through config and setConfig.
+var currentConfig atomic.Pointer[gameserverConfig]
+
+// configReload serializes reloads of the config.
+var configReload sync.Mutex
+
+// config returns the config in effect. The returned config is never changed
+// afterwards; a reload stores a new one. Keep the returned pointer if several
+// values must be consistent with each other.
+func config() *gameserverConfig {
+	return currentConfig.Load()
+}
+
+// setConfig replaces the config in effect.
+func setConfig(c *gameserverConfig) {
+	currentConfig.Store(c)
+}
+
+// reloadConfig reads the configfile again and applies all settings that can
+// change while the server is running. Changes to listeners, the database,
+// the logging backend and the round itself require a restart and are
+// ignored. The duration of the round is controlled by the admin interface.
+func reloadConfig(path string) error {
+	conf, problems := readConfig(path)
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	configReload.Lock()
+	defer configReload.Unlock()
+
+	next := *config()
+	next.Log.Level = conf.Log.Level
+	next.Network.TCP.Timeouts = conf.Network.TCP.Timeouts
+	next.Network.TCP.Limits = conf.Network.TCP.Limits
+	next.Network.WS.ValidOrigin = conf.Network.WS.ValidOrigin
+	next.Network.WS.Timeouts = conf.Network.WS.Timeouts
+	next.Network.WS.SnapshotInterval = conf.Network.WS.SnapshotInterval
+	next.Network.WS.Flags = conf.Network.WS.Flags
+	next.Admin.Token = conf.Admin.Token
+	next.Battle.AvatarPictureURL = conf.Battle.AvatarPictureURL
+	next.Battle.ShutdownTimeout = conf.Battle.ShutdownTimeout
+	next.Battle.ResultsDir = conf.Battle.ResultsDir
+	next.Battle.Rules = conf.Battle.Rules
+	setConfig(&next)
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+// noEnv is a lookup without any environment variables.
+func noEnv(key string) (string, bool) {
+	return "", false
+}
+
+// envOf returns a lookup of the environment variables in env.
+func envOf(env map[string]string) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+// validConfig returns a config without problems.
+func validConfig() *gameserverConfig {
+	c := defaultConfig()
+	c.Database.MariaDB.Host = "localhost"
+	c.Database.MariaDB.Name = "vikebot"
+	c.Network.TCP.Addr = "localhost:2400"
+	c.Network.WS.Addr = "localhost:443"
+	c.Network.WS.ValidOrigin = "watch.vikebot.com"
+	c.Battle.RoundID = 1
+	c.Battle.Map = mapGenerate
+	return c
+}
+
+func TestParseConfigTypeErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vbgs-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var tests = []struct {
+		Name     string
+		Config   string
+		Problems []string
+	}{
+		{"Test01: valid types", `{"battle":{"round_id":1}}`, nil},
+		{"Test02: every type error is reported",
+			`{"log":3,"network":{"tcp":{"limits":{"max_conns_per_ip":"8"}}},"battle":{"round_id":"1","teams":5}}`,
+			[]string{
+				"log: expected an object",
+				"network.tcp.limits.max_conns_per_ip: cannot use string as int",
+				"battle.round_id: cannot use string as int",
+				"battle.teams: cannot use number as map[string][]int",
+			}},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			path := filepath.Join(dir, string(rune('a'+i))+".json")
+			assert.NoError(ioutil.WriteFile(path, []byte(tt.Config), 0644))
+
+			_, problems := parseConfig(path, noEnv)
+			assert.Equal(tt.Problems, problems)
+		})
+	}
+}
+
+func TestParseConfigEnv(t *testing.T) {
+	assert := assert.New(t)
+
+	conf, problems := parseConfig("", envOf(map[string]string{
+		"VBGS_BATTLE_ROUND_ID": "7",
+	}))
+	assert.Empty(problems)
+	if assert.NotNil(conf) {
+		assert.Equal(7, conf.Battle.RoundID)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	var tests = []struct {
+		Name     string
+		Env      map[string]string
+		Check    func(c *gameserverConfig) bool
+		Problems []string
+	}{
+		{"Test01: string", map[string]string{"VBGS_NETWORK_TCP_ADDR": ":2500"},
+			func(c *gameserverConfig) bool { return c.Network.TCP.Addr == ":2500" }, nil},
+		{"Test02: bool", map[string]string{"VBGS_LOG_FILE_ACTIVE": "true"},
+			func(c *gameserverConfig) bool { return c.Log.File.Active }, nil},
+		{"Test03: int", map[string]string{"VBGS_BATTLE_ROUND_ID": "42"},
+			func(c *gameserverConfig) bool { return c.Battle.RoundID == 42 }, nil},
+		{"Test04: int64", map[string]string{"VBGS_BATTLE_GENERATE_SEED": "-3"},
+			func(c *gameserverConfig) bool { return c.Battle.Generate.Seed == -3 }, nil},
+		{"Test05: float", map[string]string{"VBGS_BATTLE_GENERATE_WATER_RATIO": "0.5"},
+			func(c *gameserverConfig) bool { return c.Battle.Generate.WaterRatio == 0.5 }, nil},
+		{"Test06: duration", map[string]string{"VBGS_BATTLE_DURATION": "90m"},
+			func(c *gameserverConfig) bool { return c.Battle.Duration.Duration == 90*time.Minute }, nil},
+		{"Test07: log level", map[string]string{"VBGS_LOG_LEVEL": "debug"},
+			func(c *gameserverConfig) bool { return c.Log.Level.Level == zapcore.DebugLevel }, nil},
+		{"Test08: slice", map[string]string{"VBGS_BATTLE_GENERATE_BIOMES": "grass,dirt"},
+			func(c *gameserverConfig) bool {
+				return assert.ObjectsAreEqual([]string{"grass", "dirt"}, c.Battle.Generate.Biomes)
+			}, nil},
+		{"Test09: unset variables keep the defaults", map[string]string{"VBGS_UNKNOWN": "1"},
+			func(c *gameserverConfig) bool { return assert.ObjectsAreEqual(defaultConfig(), c) }, nil},
+		{"Test10: invalid values",
+			map[string]string{
+				"VBGS_LOG_FILE_ACTIVE":             "maybe",
+				"VBGS_BATTLE_ROUND_ID":             "one",
+				"VBGS_BATTLE_GENERATE_WATER_RATIO": "much",
+				"VBGS_BATTLE_TEAMS":                "red",
+			},
+			func(c *gameserverConfig) bool { return !c.Log.File.Active && c.Battle.RoundID == 0 },
+			[]string{
+				"VBGS_LOG_FILE_ACTIVE: invalid bool \"maybe\"",
+				"VBGS_BATTLE_ROUND_ID: invalid number \"one\"",
+				"VBGS_BATTLE_TEAMS: unsupported type map",
+				"VBGS_BATTLE_GENERATE_WATER_RATIO: invalid number \"much\"",
+			}},
+		{"Test11: invalid duration is reported by validate", map[string]string{"VBGS_BATTLE_DURATION": "long"},
+			func(c *gameserverConfig) bool { return c.Battle.Duration.invalid == "long" }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			c := defaultConfig()
+			problems := applyEnv(c, envOf(tt.Env))
+			assert.Equal(tt.Problems, problems)
+			assert.True(tt.Check(c))
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var tests = []struct {
+		Name     string
+		Change   func(c *gameserverConfig)
+		Problems []string
+	}{
+		{"Test01: valid", func(c *gameserverConfig) {}, nil},
+		{"Test02: several problems at once",
+			func(c *gameserverConfig) {
+				c.Network.TCP.Addr = ""
+				c.Network.TCP.Limits.MaxConnsPerIP = -1
+				c.Battle.Duration.invalid = "long"
+				c.Battle.RoundID = 0
+			},
+			[]string{
+				"network.tcp.limits.max_conns_per_ip: mustn't be negative",
+				"battle.duration: invalid duration \"long\"",
+				"network.tcp.addr: required",
+				"battle.round_id: must be positive",
+			}},
+		{"Test03: dependent settings",
+			func(c *gameserverConfig) {
+				c.Log.File.Active = true
+				c.Log.File.Path = ""
+				c.Admin.Addr = ":2402"
+				c.Network.HTTP.TLS.Active = true
+			},
+			[]string{
+				"log.file.path: required if log.file.active",
+				"admin.token: required if admin.addr is set",
+				"network.http.tls.cert: required if network.http.tls.active",
+				"network.http.tls.pkey: required if network.http.tls.active",
+			}},
+		{"Test04: battle settings",
+			func(c *gameserverConfig) {
+				c.Battle.SpawnStrategy = "nowhere"
+				c.Battle.Generate.Width = 0
+				c.Battle.Teams = map[string][]int{"red": {1, 2}, "blue": {3}}
+			},
+			[]string{
+				"battle.generate: vbge: map width and height must be positive",
+				"battle.spawn_strategy: unknown strategy \"nowhere\"",
+			}},
+		{"Test05: negative duration",
+			func(c *gameserverConfig) { c.Network.WS.Timeouts.Ping.Duration = -time.Second },
+			[]string{"network.ws.timeouts.ping: mustn't be negative"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			c := validConfig()
+			tt.Change(c)
+			assert.Equal(tt.Problems, c.validate())
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// envPrefix is the prefix of all environment variables overriding config
+// values. The remaining name is built from the json keys of the field (e.g.
+// `VBGS_NETWORK_TCP_ADDR` overrides `network.tcp.addr`).
+const envPrefix = "VBGS_"
+
+// configLeaves calls fn for every settable value of c together with it's
+// path of json keys.
+func configLeaves(c *gameserverConfig, fn func(path []string, f reflect.Value)) {
+	walkConfig(reflect.ValueOf(c).Elem(), nil, fn)
+}
+
+func walkConfig(v reflect.Value, path []string, fn func(path []string, f reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			// unexported
+			continue
+		}
+		key := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+
+		f := v.Field(i)
+		fpath := append(append([]string{}, path...), key)
+		if _, ok := f.Addr().Interface().(encoding.TextUnmarshaler); !ok && f.Kind() == reflect.Struct {
+			walkConfig(f, fpath, fn)
+			continue
+		}
+		fn(fpath, f)
+	}
+}
+
+// envName returns the environment variable overriding the value at path.
+func envName(path []string) string {
+	return envPrefix + strings.ToUpper(strings.Join(path, "_"))
+}
+
+// applyEnv overrides all values of c whose environment variable is set.
+// lookup is usually os.LookupEnv. Values that can't be parsed are returned as
+// problems.
+func applyEnv(c *gameserverConfig, lookup func(key string) (string, bool)) (problems []string) {
+	configLeaves(c, func(path []string, f reflect.Value) {
+		name := envName(path)
+		val, ok := lookup(name)
+		if !ok {
+			return
+		}
+
+		if u, ok := f.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			err := u.UnmarshalText([]byte(val))
+			if err != nil {
+				problems = append(problems, name+": "+err.Error())
+			}
+			return
+		}
+
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(val)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				problems = append(problems, name+": invalid bool "+strconv.Quote(val))
+				return
+			}
+			f.SetBool(b)
+		case reflect.Int, reflect.Int64:
+			n, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				problems = append(problems, name+": invalid number "+strconv.Quote(val))
+				return
+			}
+			f.SetInt(n)
+		case reflect.Float64:
+			n, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				problems = append(problems, name+": invalid number "+strconv.Quote(val))
+				return
+			}
+			f.SetFloat(n)
+		case reflect.Slice:
+			if f.Type().Elem().Kind() != reflect.String {
+				problems = append(problems, name+": unsupported type "+f.Type().String())
+				return
+			}
+			// comma separated list
+			f.Set(reflect.ValueOf(strings.Split(val, ",")))
+		default:
+			problems = append(problems, name+": unsupported type "+f.Kind().String())
+		}
+	})
+	return problems
+}
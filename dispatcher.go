@@ -2,9 +2,8 @@ package main
 
 import (
 	"encoding/json"
-	"strconv"
-
-	"go.uber.org/zap"
+	"fmt"
+	"sync"
 )
 
 const (
@@ -12,135 +11,173 @@ const (
 	statusInvalidJSON         = "Invalid JSON format"
 )
 
-func dispatch(c *ntcpclient, data []byte, packet typePacket) {
-	var err error
-
-	// Switch between packet types and dispatch them between the different op funcs
-	switch *packet.Type {
-	case "login":
-		var login loginPacket
-		err = json.Unmarshal(data, &login)
-		if err != nil {
-			c.Respond(statusInvalidJSON)
-			return
-		}
-		opLogin(c, login)
-		return
-	case "clienthello":
-		var clienthello xhelloPacket
-		err = json.Unmarshal(data, &clienthello)
-		if err != nil {
-			c.Respond(statusInvalidJSON)
-			return
-		}
-		opClienthello(c, clienthello)
-		return
-	case "agreeconn":
-		// Check if this client has already a agreed connection
-		if err = ntcpRegistry.Put(c); err != nil {
-			log.Warn("multiple connections for same user", zap.Error(err))
-			c.Respond("Connection already open - Please close any previous connections before initializing a new one.")
-			return
-		}
+// opHandlerFunc processes the raw data of a single packet for op o.
+type opHandlerFunc func(c *ntcpclient, o *op, data []byte)
 
-		c.AgreeconnDone = true
-		c.Authenticated = true
-		c.Player = battle.Players[c.UserID]
+// opMiddleware wraps an opHandlerFunc. Middlewares are applied uniformly to
+// all ops and can decide to not call next (e.g. to reject the packet).
+type opMiddleware func(next opHandlerFunc) opHandlerFunc
 
-		c.RespondNil()
-		dist.GetClient(strconv.Itoa(c.UserID)).PushInfo(true, c.IP, c.SDK, c.SDKLink, c.OS, c.Log)
-		return
-	case "rotate":
-		var rotate rotatePacket
-		err = json.Unmarshal(data, &rotate)
-		if err != nil {
-			c.Respond(statusInvalidJSON)
-			return
-		}
-		opRotate(c, rotate)
-		return
-	case "move":
-		var move movePacket
-		err = json.Unmarshal(data, &move)
-		if err != nil {
-			c.Respond(statusInvalidJSON)
-			return
-		}
-		opMove(c, move)
-		return
-	case "radar":
-		var radar radarPacket
-		err = json.Unmarshal(data, &radar)
-		if err != nil {
-			c.Respond(statusInvalidJSON)
-			return
-		}
-		opRadar(c, radar)
-		return
-	case "scout":
-		var scout scoutPacket
-		err = json.Unmarshal(data, &scout)
-		if err != nil {
-			c.Respond(statusInvalidJSON)
-			return
-		}
-		opScout(c, scout)
+// op describes a single operation a client can call.
+type op struct {
+	// Name is the packet type used by clients to call the op
+	Name string
+
+	// NewPacket allocates a new packet the raw data is unmarshaled into
+	NewPacket func() interface{}
+
+	// Validate checks the fields of the unmarshaled packet. The returned
+	// error is sent to the client. Validate is optional
+	Validate func(packet interface{}) *opError
+
+	// Cooldown takes the op's rate limit of the client's player (Player.Rl).
+	// It's taken by rateLimitMiddleware before the packet is unmarshaled and
+	// validated. Cooldown is optional
+	Cooldown func(c *ntcpclient)
+
+	// Handler executes the op with the validated packet
+	Handler func(c *ntcpclient, packet interface{})
+
+	// Handshake marks ops that are part of the connection handshake and
+	// therefore callable by unauthenticated clients
+	Handshake bool
+
+	// Persistent marks ops that only make sense on a persistent connection
+	Persistent bool
+
+	// Passive marks ops that don't change the game and are therefore
+	// callable while the round is paused
+	Passive bool
+}
+
+type opRegistry struct {
+	ops         map[string]*op
+	middlewares []opMiddleware
+	baton       sync.RWMutex
+}
+
+// ops contains all registered ops. Op files register themselves during init.
+var ops = &opRegistry{
+	ops: map[string]*op{},
+}
+
+// Register adds o to the registry. Registering the same name twice panics.
+func (r *opRegistry) Register(o *op) {
+	r.baton.Lock()
+	defer r.baton.Unlock()
+
+	if _, ok := r.ops[o.Name]; ok {
+		panic(fmt.Sprintf("op %q registered twice", o.Name))
+	}
+	r.ops[o.Name] = o
+}
+
+// Use appends the middleware m to the chain applied to all ops. Middlewares
+// are called in the order they were added.
+func (r *opRegistry) Use(m opMiddleware) {
+	r.baton.Lock()
+	defer r.baton.Unlock()
+
+	r.middlewares = append(r.middlewares, m)
+}
+
+// Get returns the op registered for name or nil.
+func (r *opRegistry) Get(name string) *op {
+	r.baton.RLock()
+	defer r.baton.RUnlock()
+
+	return r.ops[name]
+}
+
+// Dispatch calls the op referenced by the packet's type through all
+// middlewares.
+func (r *opRegistry) Dispatch(c *ntcpclient, data []byte, packet typePacket) {
+	o := r.Get(*packet.Type)
+	if o == nil {
+		c.CurType = "forbidden"
+		c.RespondErr(errUnknownType.New("Invalid packet. '.type' unknown").With("type", *packet.Type))
 		return
-	case "environment":
-		var environment environmentPacket
-		err = json.Unmarshal(data, &environment)
-		if err != nil {
-			c.Respond(statusInvalidJSON)
-			return
-		}
-	case "watch":
-		var watch watchPacket
-		err = json.Unmarshal(data, &watch)
-		if err != nil {
-			c.Respond(statusInvalidJSON)
-			return
-		}
-		opWatch(c, watch)
+	}
+
+	r.baton.RLock()
+	h := opHandlerFunc(execOp)
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		h = r.middlewares[i](h)
+	}
+	r.baton.RUnlock()
+
+	h(c, o, data)
+}
+
+// execOp is the innermost opHandlerFunc. It unmarshals and validates the
+// packet and calls the op's handler.
+func execOp(c *ntcpclient, o *op, data []byte) {
+	packet := o.NewPacket()
+	err := json.Unmarshal(data, packet)
+	if err != nil {
+		c.RespondErr(errInvalidJSON.New(statusInvalidJSON))
 		return
-	case "attack":
-		var attack attackPacket
-		err = json.Unmarshal(data, &attack)
-		if err != nil {
-			c.Respond(statusInvalidJSON)
+	}
+
+	if o.Validate != nil {
+		if opErr := o.Validate(packet); opErr != nil {
+			c.RespondErr(opErr)
 			return
 		}
-		opAttack(c, attack)
-		return
-	case "defend":
-		var defend defendPacket
-		err = json.Unmarshal(data, &defend)
-		if err != nil {
-			c.Respond(statusInvalidJSON)
+	}
+
+	o.Handler(c, packet)
+}
+
+// authMiddleware rejects all non-handshake ops of unauthenticated clients.
+func authMiddleware(next opHandlerFunc) opHandlerFunc {
+	return func(c *ntcpclient, o *op, data []byte) {
+		if !o.Handshake && !c.Authenticated {
+			c.RespondErr(errNotAuth.New("You aren't allowed to send any packet type previous to a successful \"agreeconn\""))
 			return
 		}
-		opDefend(c, defend)
-		return
-	case "undefend":
-		var undefend undefendPacket
-		err = json.Unmarshal(data, &undefend)
-		if err != nil {
-			c.Respond(statusInvalidJSON)
-			return
+		next(c, o, data)
+	}
+}
+
+// rateLimitMiddleware blocks the client till the op's cooldown allows it's
+// execution. It runs before the packet is validated, so invalid packets can't
+// be sent faster than valid ones.
+func rateLimitMiddleware(next opHandlerFunc) opHandlerFunc {
+	return func(c *ntcpclient, o *op, data []byte) {
+		if o.Cooldown != nil {
+			o.Cooldown(c)
 		}
-		opUndefend(c, undefend)
-		return
-	case "health":
-		var health healthPacket
-		err = json.Unmarshal(data, &health)
-		if err != nil {
-			c.Respond(statusInvalidJSON)
-			return
+		next(c, o, data)
+	}
+}
+
+// phaseMiddleware rejects all ops changing the game while the round isn't
+// running.
+func phaseMiddleware(next opHandlerFunc) opHandlerFunc {
+	return func(c *ntcpclient, o *op, data []byte) {
+		if !o.Handshake && !o.Passive {
+			switch round.Phase() {
+			case phasePaused:
+				c.RespondErr(errRoundPaused.New("The round is paused. Try again after it has been resumed"))
+				return
+			case phaseEnded:
+				c.RespondErr(errRoundFinished.New("The round is already over"))
+				return
+			}
 		}
-		opHealth(c, health)
-		return
-	default:
-		c.CurType = "forbidden"
-		c.Respond("Invalid packet. '.type' unknown")
-		return
+		next(c, o, data)
 	}
 }
+
+func init() {
+	ops.Use(metricsMiddleware)
+	ops.Use(authMiddleware)
+	ops.Use(rateLimitMiddleware)
+	ops.Use(auditMiddleware)
+	ops.Use(phaseMiddleware)
+}
+
+func dispatch(c *ntcpclient, data []byte, packet typePacket) {
+	ops.Dispatch(c, data, packet)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vikebot/vbgs/vbge"
+)
+
+type testCooldownPacket struct {
+	Type string `json:"type"`
+	Obj  struct {
+		Value *int `json:"value"`
+	} `json:"obj"`
+}
+
+// testCooldowns and testHandled count the calls of the testcooldown op
+var testCooldowns, testHandled int
+
+func init() {
+	ops.Register(&op{
+		Name:      "testcooldown",
+		NewPacket: func() interface{} { return &testCooldownPacket{} },
+		Validate: func(p interface{}) *opError {
+			if p.(*testCooldownPacket).Obj.Value == nil {
+				return errInvalidPacket.New("Invalid packet. '.obj.value' missing").With("field", ".obj.value")
+			}
+			return nil
+		},
+		Cooldown: func(c *ntcpclient) { testCooldowns++ },
+		Handler: func(c *ntcpclient, p interface{}) {
+			testHandled++
+			c.RespondNil()
+		},
+	})
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	var tests = []struct {
+		Name          string
+		Authenticated bool
+		Phase         roundPhase
+		Packet        string
+		Error         string
+		Cooldown      bool
+		Handled       bool
+	}{
+		{"Test01: unauthenticated clients don't take cooldowns", false, phaseRunning,
+			`{"type":"testcooldown","obj":{"value":1}}`, "handshake_order", false, false},
+		{"Test02: cooldown is taken before validation", true, phaseRunning,
+			`{"type":"testcooldown","obj":{}}`, "invalid_packet", true, false},
+		{"Test03: cooldown is taken before the phase check", true, phasePaused,
+			`{"type":"testcooldown","obj":{"value":1}}`, "round_paused", true, false},
+		{"Test04: valid packet", true, phaseRunning,
+			`{"type":"testcooldown","obj":{"value":1}}`, "", true, true},
+		{"Test05: unknown op", true, phaseRunning,
+			`{"type":"testunknown","obj":{}}`, "unknown_type", false, false},
+	}
+
+	prev := round
+	defer func() { round = prev }()
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			round = newRoundState()
+			round.phase = tt.Phase
+			testCooldowns, testHandled = 0, 0
+
+			c := newTestClient("10.0.0.1")
+			c.Authenticated = tt.Authenticated
+			c.send(tt.Packet)
+
+			rs := c.responses()
+			if assert.Len(rs, 1) {
+				if tt.Error == "" {
+					assert.Nil(rs[0]["errorinfo"])
+				} else if assert.NotNil(rs[0]["errorinfo"]) {
+					assert.Equal(tt.Error, rs[0]["errorinfo"].(map[string]interface{})["name"])
+				}
+			}
+			assert.Equal(tt.Cooldown, testCooldowns == 1)
+			assert.Equal(tt.Handled, testHandled == 1)
+		})
+	}
+}
+
+func TestCooldownFollowsRules(t *testing.T) {
+	assert := assert.New(t)
+	defer vbge.SetRules(vbge.DefaultRules())
+
+	c := newTestClient("10.0.0.1")
+	c.Player = &vbge.Player{Rl: vbge.NewOpLimitations()}
+	rotate := ops.Get("rotate")
+
+	// takes returns the duration n cooldowns of the rotate op take
+	takes := func(n int) time.Duration {
+		start := time.Now()
+		for i := 0; i < n; i++ {
+			rotate.Cooldown(c.ntcpclient)
+		}
+		return time.Since(start)
+	}
+
+	var tests = []struct {
+		Name     string
+		Rate     int
+		Min, Max time.Duration
+	}{
+		{"Test01: slow rate", 10, 250 * time.Millisecond, time.Second},
+		{"Test02: fast rate", 200, 0, 100 * time.Millisecond},
+		{"Test03: slow rate again", 10, 250 * time.Millisecond, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			r := vbge.DefaultRules()
+			r.Throttles.Rotate = tt.Rate
+			assert.NoError(vbge.SetRules(r))
+
+			// the first cooldown after a change isn't throttled
+			d := takes(4)
+			assert.True(d >= tt.Min && d <= tt.Max, "4 cooldowns took %s", d)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/vikebot/vbgs/vbge"
+)
+
+// errCode is a single entry of the error catalogue. Code and Name are stable
+// and can be used by SDKs to identify errors without matching the human
+// readable message. Retryable hints whether sending the same packet again
+// could succeed.
+type errCode struct {
+	Code      int
+	Name      string
+	Retryable bool
+}
+
+// New creates an opError of the code with the human readable message msg.
+func (ec errCode) New(msg string) *opError {
+	return &opError{
+		errCode: ec,
+		Msg:     msg,
+	}
+}
+
+// Newf is like New but formats the message according to format.
+func (ec errCode) Newf(format string, a ...interface{}) *opError {
+	return ec.New(fmt.Sprintf(format, a...))
+}
+
+// The error catalogue. Codes are grouped by their first digit: 1xxx are
+// protocol errors, 2xxx authentication/session errors and 3xxx game errors.
+// Never change or reuse an existing code.
+var (
+	errInternal        = errCode{1000, "internal_error", true}
+	errInvalidJSON     = errCode{1001, "invalid_json", false}
+	errInvalidPacket   = errCode{1002, "invalid_packet", false}
+	errUnknownType     = errCode{1003, "unknown_type", false}
+	errInvalidCipher   = errCode{1004, "invalid_cipher", false}
+	errPcMismatch      = errCode{1005, "pc_mismatch", false}
+	errHandshakeOrder  = errCode{1006, "handshake_order", false}
+	errAlreadyDone     = errCode{1007, "already_done", false}
+	errPacketSize      = errCode{1008, "packet_too_large", false}
+	errNotInBatch      = errCode{1009, "not_allowed_in_batch", false}
+	errNotAuth         = errCode{2000, "not_authenticated", false}
+	errUnknownTicket   = errCode{2001, "unknown_roundticket", false}
+	errRoundFinished   = errCode{2002, "round_finished", false}
+	errConnectionOpen  = errCode{2003, "connection_open", true}
+	errUnknownSession  = errCode{2004, "unknown_resumetoken", false}
+	errInvalidHello    = errCode{2005, "invalid_challenge", false}
+	errNotJoined       = errCode{2006, "not_joined", false}
+	errRoundPaused     = errCode{2007, "round_paused", true}
+	errBanned          = errCode{2008, "banned", false}
+	errGame            = errCode{3000, "game_error", false}
+	errNoEnemy         = errCode{3001, "no_enemy", true}
+	errOutOfMap        = errCode{3002, "out_of_map", false}
+	errMoveOutOfMap    = errCode{3003, "move_out_of_map", false}
+	errHasResident     = errCode{3004, "has_resident", true}
+	errInaccessable    = errCode{3005, "inaccessable", false}
+	errAlreadyDefend   = errCode{3006, "already_defending", false}
+	errAlreadyUndefend = errCode{3007, "already_undefending", false}
+	errDefendingMove   = errCode{3008, "defending_cant_move", false}
+)
+
+// vbgeErrCodes maps the sentinel errors of vbge to their catalogue entries
+var vbgeErrCodes = map[error]errCode{
+	vbge.ErrNoEnemy:             errNoEnemy,
+	vbge.ErrOutOfMap:            errOutOfMap,
+	vbge.ErrNoMoveOutOfMap:      errMoveOutOfMap,
+	vbge.ErrHasResident:         errHasResident,
+	vbge.ErrInaccessable:        errInaccessable,
+	vbge.ErrAlreadyDef:          errAlreadyDefend,
+	vbge.ErrAlreadyUndef:        errAlreadyUndefend,
+	vbge.ErrCantMoveOFDefending: errDefendingMove,
+}
+
+// opError is an error that is sent to the client together with it's
+// catalogue entry and optional details.
+type opError struct {
+	errCode
+	Msg     string
+	Details map[string]interface{}
+}
+
+func (e *opError) Error() string {
+	return e.Msg
+}
+
+// With adds the key value pair to the details of the error and returns the
+// error itself for chaining.
+func (e *opError) With(key string, value interface{}) *opError {
+	if e.Details == nil {
+		e.Details = map[string]interface{}{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+// gameError converts an error returned from vbge into an opError. Unknown
+// errors are reported as generic game errors.
+func gameError(err error) *opError {
+	if ec, ok := vbgeErrCodes[err]; ok {
+		return ec.New(err.Error())
+	}
+	return errGame.New(err.Error())
+}
+
+// responseError is the machine readable part of an error inside a response.
+type responseError struct {
+	Code      int                    `json:"code"`
+	Name      string                 `json:"name"`
+	Retryable bool                   `json:"retryable"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+func newResponseError(e *opError) *responseError {
+	if e == nil {
+		return nil
+	}
+	return &responseError{
+		Code:      e.Code,
+		Name:      e.Name,
+		Retryable: e.Retryable,
+		Details:   e.Details,
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/vikebot/vbdb"
+	"go.uber.org/zap"
+)
+
+// Listener states reported by the readiness endpoint
+const (
+	listenerReady     = "ready"
+	listenerListening = "listening"
+	listenerStopped   = "stopped"
+)
+
+// healthDBTimeout is the maximum time the readiness probe waits for the
+// database.
+const healthDBTimeout = 2 * time.Second
+
+// listenerStates tracks the state of all network listeners.
+type listenerStates struct {
+	m     map[string]string
+	baton sync.Mutex
+}
+
+var listeners = &listenerStates{
+	m: map[string]string{},
+}
+
+// Set sets the state of the listener name.
+func (l *listenerStates) Set(name, state string) {
+	l.baton.Lock()
+	defer l.baton.Unlock()
+
+	l.m[name] = state
+}
+
+// All returns a copy of the states of all listeners.
+func (l *listenerStates) All() map[string]string {
+	l.baton.Lock()
+	defer l.baton.Unlock()
+
+	all := make(map[string]string, len(l.m))
+	for name, state := range l.m {
+		all[name] = state
+	}
+	return all
+}
+
+// startTime is used to report the uptime
+var startTime = time.Now()
+
+func init() {
+	adminMux.HandleFunc("/healthz", healthzHandler)
+	adminMux.HandleFunc("/readyz", readyzHandler)
+}
+
+type buildInfo struct {
+	Version string `json:"version"`
+	Uptime  string `json:"uptime"`
+}
+
+func newBuildInfo() buildInfo {
+	v := Version
+	if v == "" {
+		v = "dev"
+	}
+	return buildInfo{
+		Version: v,
+		Uptime:  time.Since(startTime).Round(time.Second).String(),
+	}
+}
+
+// healthzHandler reports that the process is alive. It doesn't check any
+// dependencies, so orchestrators don't restart the server because of an
+// unavailable database.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	adminRespond(w, http.StatusOK, struct {
+		Status string    `json:"status"`
+		Build  buildInfo `json:"build"`
+	}{
+		"ok",
+		newBuildInfo(),
+	})
+}
+
+type readyzDistributor struct {
+	Running bool `json:"running"`
+	Queued  int  `json:"queued"`
+}
+
+type readyzResponse struct {
+	Ready       bool              `json:"ready"`
+	Build       buildInfo         `json:"build"`
+	Database    bool              `json:"database"`
+	Listeners   map[string]string `json:"listeners"`
+	Round       string            `json:"round"`
+	Distributor readyzDistributor `json:"distributor"`
+}
+
+// readyzHandler reports whether the server accepts bots and viewers. It
+// responds with 503 if any of the checked components isn't ready.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	resp := readyzResponse{
+		Build:     newBuildInfo(),
+		Database:  healthDatabase(),
+		Listeners: listeners.All(),
+		Round:     round.Phase().String(),
+	}
+
+	resp.Distributor.Running = dist != nil && !isClosed(distStop)
+	if dist != nil {
+		for _, userID := range dist.UserIDs() {
+			resp.Distributor.Queued += dist.GetClient(userID).QueueLength()
+		}
+	}
+
+	resp.Ready = resp.Database && resp.Distributor.Running && round.Phase() != phaseEnded
+	for _, state := range resp.Listeners {
+		if state != listenerListening {
+			resp.Ready = false
+		}
+	}
+
+	status := http.StatusOK
+	if !resp.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	adminRespond(w, status, resp)
+}
+
+// healthRoundExists queries the database for the readiness probe. Tests
+// replace it, so the probe can be checked without database.
+var healthRoundExists = vbdb.RoundExistsCtx
+
+// healthDatabase reports whether the database answers a simple query in
+// time.
+func healthDatabase() bool {
+	ok := make(chan bool, 1)
+	go func() {
+		_, success := healthRoundExists(config().Battle.RoundID, log.With(zap.String("probe", "readyz")))
+		ok <- success
+	}()
+
+	select {
+	case success := <-ok:
+		return success
+	case <-time.After(healthDBTimeout):
+		return false
+	}
+}
+
+func isClosed(ch chan struct{}) bool {
+	if ch == nil {
+		return true
+	}
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestReadyzHandler(t *testing.T) {
+	healthy := map[string]string{
+		"ntcp": listenerListening,
+		"nws":  listenerListening,
+	}
+
+	var tests = []struct {
+		Name      string
+		Listeners map[string]string
+		Phase     roundPhase
+		Database  bool
+		Status    int
+	}{
+		{"Test01: all healthy", healthy, phaseRunning, true, http.StatusOK},
+		{"Test02: paused round is ready", healthy, phasePaused, true, http.StatusOK},
+		{"Test03: listener not yet listening",
+			map[string]string{"ntcp": listenerListening, "nws": listenerReady},
+			phaseRunning, true, http.StatusServiceUnavailable},
+		{"Test04: stopped listener",
+			map[string]string{"ntcp": listenerStopped, "nws": listenerListening},
+			phaseRunning, true, http.StatusServiceUnavailable},
+		{"Test05: ended round", healthy, phaseEnded, true, http.StatusServiceUnavailable},
+		{"Test06: database unavailable", healthy, phaseRunning, false, http.StatusServiceUnavailable},
+	}
+
+	prevListeners, prevRound, prevProbe := listeners, round, healthRoundExists
+	defer func() { listeners, round, healthRoundExists = prevListeners, prevRound, prevProbe }()
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			listeners = &listenerStates{m: map[string]string{}}
+			for name, state := range tt.Listeners {
+				listeners.Set(name, state)
+			}
+			round = newRoundState()
+			round.phase = tt.Phase
+			healthRoundExists = func(roundID int, ctx *zap.Logger) (bool, bool) {
+				return true, tt.Database
+			}
+
+			w := httptest.NewRecorder()
+			readyzHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(tt.Status, w.Code)
+
+			var resp readyzResponse
+			if assert.NoError(json.Unmarshal(w.Body.Bytes(), &resp)) {
+				assert.Equal(tt.Status == http.StatusOK, resp.Ready)
+				assert.Equal(tt.Database, resp.Database)
+				assert.Equal(tt.Listeners, resp.Listeners)
+				assert.Equal(tt.Phase.String(), resp.Round)
+				assert.True(resp.Distributor.Running)
+			}
+		})
+	}
+}
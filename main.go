@@ -1,17 +1,13 @@
 package main
 
 import (
-	"encoding/json"
-	"flag"
 	"fmt"
-	"io/ioutil"
-	logSimple "log"
 	"math/rand"
 	"os"
-	"path"
 	"strconv"
 	"time"
 
+	"github.com/vikebot/vbgs/pkg/errreport"
 	"github.com/vikebot/vbgs/pkg/ntfydistr"
 
 	"github.com/vikebot/vbcore"
@@ -19,6 +15,7 @@ import (
 	"github.com/vikebot/vbgs/vbge"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 var (
@@ -29,13 +26,15 @@ var (
 )
 
 var (
-	log    *zap.Logger
-	config *gameserverConfig
+	log *zap.Logger
 
 	// battle is the game (mapentity with players)
 	battle          *vbge.Battle
 	envDisableCrypt bool
 	dist            *ntfydistr.Distributor
+
+	// logOutput receives the console log
+	logOutput = os.Stdout
 )
 
 func gsInit() {
@@ -56,10 +55,10 @@ func gsInit() {
 
 	log.Info("init database connections")
 	vbdbConfig := &vbdb.Config{
-		DbAddr: vbcore.NewEndpointAddr(config.Database.MariaDB.Host),
-		DbUser: config.Database.MariaDB.User,
-		DbPass: config.Database.MariaDB.Password,
-		DbName: config.Database.MariaDB.Name,
+		DbAddr: vbcore.NewEndpointAddr(config().Database.MariaDB.Host),
+		DbUser: config().Database.MariaDB.User,
+		DbPass: config().Database.MariaDB.Password,
+		DbName: config().Database.MariaDB.Name,
 	}
 	err = vbdb.Init(vbdbConfig, log)
 	if err != nil {
@@ -67,35 +66,60 @@ func gsInit() {
 	}
 
 	registryInit()
+	auditInit()
+	rulesInit()
 }
 
 func battleInit(joinedPlayers []int) {
-	dir := "config/map"
-	filename := "small_map.json"
+	m := &vbge.MapFile{}
+	if config().Battle.Map == mapGenerate {
+		opt := config().Battle.Generate
+		if opt.Seed == 0 {
+			opt.Seed = time.Now().UnixNano()
+		}
 
-	file, err := os.Open(path.Join(dir, filename))
-	if err != nil {
-		log.Fatal("unable to open file", zap.String("filename", filename), zap.Error(err))
+		var err error
+		m.Blocks, err = vbge.GenerateMap(opt)
+		if err != nil {
+			log.Fatal("failed to generate map", zap.Error(err))
+		}
+		// the seed reproduces the map (e.g. for rendering replays)
+		log.Info("generated map", zap.Int64("seed", opt.Seed), zap.Int("width", opt.Width), zap.Int("height", opt.Height))
+	} else {
+		var problems []string
+		m, problems = checkMap(config().Battle.Map)
+		if len(problems) > 0 {
+			log.Fatal("invalid map", zap.String("path", config().Battle.Map), zap.Strings("problems", problems))
+		}
 	}
-	defer file.Close()
+	width, height := len(m.Blocks[0]), len(m.Blocks)
 
-	data, err := ioutil.ReadAll(file)
-	if err != nil {
-		log.Fatal("unable to read file", zap.String("filename", filename), zap.Error(err))
+	battle = &vbge.Battle{
+		Map:     vbge.NewMapEntityFromMap(width, height, m.Blocks),
+		Players: make(map[int]*vbge.Player),
 	}
+	battle.Map.SpawnPoints = m.Spawns
+	battle.Map.SpawnZones = m.Zones
 
-	var blocks [][]string
-	err = json.Unmarshal(data, &blocks)
+	strategy, err := vbge.NewSpawnStrategy(config().Battle.SpawnStrategy, userTeams(config().Battle.Teams))
 	if err != nil {
-		log.Fatal("unable to unmarshal json", zap.String("filename", filename), zap.Error(err))
-	}
-
-	battle = &vbge.Battle{
-		Map:     vbge.NewMapEntityFromMap(vbge.MapHeight, vbge.MapWidth, blocks),
-		Players: make(map[int]*vbge.Player),
+		log.Fatal("invalid spawn strategy", zap.Error(err))
 	}
+	battle.Map.SpawnStrategy = strategy
 	// MapSize
-	vbge.SetMapDimensions(vbge.MapHeight, vbge.MapWidth)
+	vbge.SetMapDimensions(width, height)
+
+	// Players only spawn in the main region. Unreachable areas are either
+	// wasted space or a hideout for bots that spawned there before
+	analysis := battle.Map.Analysis
+	if warnings := analysis.Warnings(); len(warnings) > 0 {
+		if config().Battle.RejectUnreachable {
+			log.Fatal("map has unreachable areas", zap.Strings("warnings", warnings))
+		}
+		log.Warn("map has unreachable areas",
+			zap.Int("unreachable", analysis.Unreachable()),
+			zap.Strings("warnings", warnings))
+	}
 
 	for _, j := range joinedPlayers {
 		p, err := vbge.NewPlayerWithSpawn(j, battle.Map)
@@ -107,81 +131,19 @@ func battleInit(joinedPlayers []int) {
 }
 
 func main() {
-	conf := flag.String("config", "", "path to config file")
-	version := flag.Bool("version", false, "only display the version of vbgs")
-	flag.Parse()
-
-	// Only print version
-	if version != nil && *version {
-		fmt.Println("vikebot/vbgs@" + Version)
-		return
-	}
-
-	if conf == nil || *conf == "" {
-		logSimple.Fatal("no gameserver config defined")
-	}
-	config = loadConfig(*conf)
-
-	// init zap logging
-	initLog()
-	defer log.Sync()
-
-	// print version
-	if len(Version) != 0 {
-		log.Info("running vbgs at version", zap.String("version", Version))
-	}
-
-	// Prepare basic stuff of the server and init our battle (fetch map)
-	gsInit()
-
-	// getAllPlayers
-	joinedPlayers := getJoinedPlayers()
-
-	// init the battle
-	battleInit(joinedPlayers)
-
-	// init the distributor
-	distributorInit(joinedPlayers)
-	defer dist.Close()
-
-	// Start and shutdown channels
-	startChan := make(chan bool)
-	shutdownChan := make(chan bool)
-
-	// Start the network services
-	ntcpInit(startChan, shutdownChan)
-	nwsInit(startChan, shutdownChan)
-
-	// Sleep till start
-	startTime := time.Now().UTC().Add(time.Second * 2)
-	sleepDuration := startTime.Sub(time.Now().UTC())
-	log.Info("prepared services. sleeping till starttime",
-		zap.Time("starttime", startTime),
-		zap.Duration("sleeping", sleepDuration))
-	time.Sleep(sleepDuration)
-
-	// Activate services that listen on starting channel signal
-	startChan <- true
-	startChan <- true
-	startChan <- true
-
-	// Shutdown services in on hour
-	log.Info("started services. sleeping till shutdown",
-		zap.Time("shutdowntim", time.Now().UTC().Add(time.Hour*1)))
-	time.Sleep(time.Hour * 1) // Time of a game
-	shutdownChan <- true
+	runCommand(os.Args[1:])
 }
 
 func initLog() {
 	// Logging server
 	enablerFunc := zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-		return lvl >= config.Log.Level
+		return lvl >= config().Log.Level.Level
 	})
 	var encoder zapcore.Encoder
-	switch config.Log.Config {
+	switch config().Log.Config {
 	case "development", "dev":
 		zapConfig := zap.NewDevelopmentConfig()
-		if config.Log.Colored {
+		if config().Log.Colored {
 			zapConfig.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 		}
 		encoder = zapcore.NewConsoleEncoder(zapConfig.EncoderConfig)
@@ -193,14 +155,46 @@ func initLog() {
 		fmt.Println("config.log.config is of unknown type. only 'development', 'dev', 'production' and 'prod' are allowed")
 		os.Exit(-1)
 	}
-	core := zapcore.NewTee(zapcore.NewCore(encoder, zapcore.Lock(os.Stdout), enablerFunc))
-	log = zap.New(core)
+	cores := []zapcore.Core{
+		zapcore.NewCore(encoder, zapcore.Lock(logOutput), enablerFunc),
+	}
+
+	// Rotating log file. Always JSON encoded, so it stays machine readable
+	if config().Log.File.Active {
+		fileConfig := zap.NewProductionConfig()
+		fileConfig.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+		cores = append(cores, zapcore.NewCore(
+			zapcore.NewJSONEncoder(fileConfig.EncoderConfig),
+			zapcore.AddSync(&lumberjack.Logger{
+				Filename:   config().Log.File.Path,
+				MaxSize:    config().Log.File.MaxSize,
+				MaxAge:     config().Log.File.MaxAge,
+				MaxBackups: config().Log.File.MaxBackups,
+			}),
+			enablerFunc))
+	}
+
+	// Report all errors to sentry
+	if config().Log.Sentry.Active {
+		environment := config().Log.Sentry.Environment
+		if environment == "" {
+			environment = config().Instance
+		}
+		reporter, err := errreport.NewSentry(config().Log.Sentry.DSN, environment, Version)
+		if err != nil {
+			fmt.Println("failed to init sentry: " + err.Error())
+			os.Exit(-1)
+		}
+		cores = append(cores, errreport.NewCore(zapcore.ErrorLevel, reporter))
+	}
+
+	log = zap.New(zapcore.NewTee(cores...))
 }
 
 func getJoinedPlayers() (joinedPlayers []int) {
-	joined, success := vbdb.JoinedUsersCtx(config.Battle.RoundID, log)
+	joined, success := vbdb.JoinedUsersCtx(config().Battle.RoundID, log)
 	if !success {
-		log.Fatal("unable to load users for this round", zap.Int("round_id", config.Battle.RoundID))
+		log.Fatal("unable to load users for this round", zap.Int("round_id", config().Battle.RoundID))
 	}
 
 	return joined
@@ -212,7 +206,6 @@ func distributorInit(joinedPlayers []int) {
 		joinedStr[idx] = strconv.Itoa(id)
 	}
 
-	// TODO: make global stop chan
-	stop := make(chan struct{})
-	dist = ntfydistr.NewDistributor(joinedStr, stop, log.Named("nftydistr.distributor"))
+	distStop = make(chan struct{})
+	dist = ntfydistr.NewDistributor(joinedStr, distStop, log.Named("nftydistr.distributor"))
 }
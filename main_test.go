@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vikebot/vbgs/vbge"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	log = zap.NewNop()
+	setConfig(defaultConfig())
+	envDisableCrypt = true
+	registryInit()
+	guard = newNtcpGuard()
+	battle = &vbge.Battle{Players: map[int]*vbge.Player{}}
+	distributorInit([]int{1, 2, 3})
+
+	os.Exit(m.Run())
+}
+
+// syncBuffer is a buffer safe for concurrent use. Pushes are written from
+// other goroutines than responses.
+type syncBuffer struct {
+	buf   bytes.Buffer
+	baton sync.Mutex
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.baton.Lock()
+	defer b.baton.Unlock()
+
+	return b.buf.Write(p)
+}
+
+// take returns the buffer's content and resets it.
+func (b *syncBuffer) take() []byte {
+	b.baton.Lock()
+	defer b.baton.Unlock()
+
+	buf := append([]byte{}, b.buf.Bytes()...)
+	b.buf.Reset()
+	return buf
+}
+
+// testClient is a ntcpclient connected through an in-memory pipe. All
+// responses are written into out.
+type testClient struct {
+	*ntcpclient
+	out  *syncBuffer
+	peer net.Conn
+}
+
+func newTestClient(ip string) *testClient {
+	conn, peer := net.Pipe()
+	out := &syncBuffer{}
+
+	c := newNtcpclient(conn, zap.NewNop())
+	c.PureIP = ip
+	c.Out = out
+
+	return &testClient{
+		ntcpclient: c,
+		out:        out,
+		peer:       peer,
+	}
+}
+
+// send passes the packet through the same handler the ntcp read loop uses.
+func (c *testClient) send(packet string) {
+	packetHandler(c.ntcpclient, []byte(packet))
+}
+
+// responses returns all responses sent since the last call.
+func (c *testClient) responses() []map[string]interface{} {
+	var rs []map[string]interface{}
+	s := bufio.NewScanner(bytes.NewReader(c.out.take()))
+	for s.Scan() {
+		var r map[string]interface{}
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			panic(err)
+		}
+		rs = append(rs, r)
+	}
+	return rs
+}
+
+// serve handles the client's connection like the ntcp listener does, till
+// the connection is closed.
+func (c *testClient) serve() {
+	go func() {
+		defer close(c.done)
+		ntcp(c.ntcpclient)
+	}()
+}
+
+// closed reports whether the client's connection has been closed.
+func (c *testClient) closed() bool {
+	return c.Conn.SetDeadline(time.Time{}) != nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/vikebot/vbgs/vbge"
+)
+
+// mapGenerate is used as `battle.map` to generate the map with the options of
+// `battle.generate`
+const mapGenerate = "generate"
+
+// loadMap reads the map file at path (see vbge.MapFile for the format).
+func loadMap(path string) (*vbge.MapFile, error) {
+	data, err := ioutil.ReadFile(path) /* #nosec G304 */
+	if err != nil {
+		return nil, err
+	}
+
+	var f vbge.MapFile
+	err = json.Unmarshal(data, &f)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// saveMap writes f in the format of loadMap.
+func saveMap(path string, f *vbge.MapFile) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// checkMap loads and validates the map at path. It returns the map and a
+// description of every problem.
+func checkMap(path string) (*vbge.MapFile, []string) {
+	f, err := loadMap(path)
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+	return f, f.Validate()
+}
+
+// summarizeProblems shortens long problem lists (e.g. of a broken map) to
+// the first problem and the number of the remaining ones.
+func summarizeProblems(problems []string) string {
+	if len(problems) == 1 {
+		return problems[0]
+	}
+	return fmt.Sprintf("%s (and %d more problems)", problems[0], len(problems)-1)
+}
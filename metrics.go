@@ -0,0 +1,139 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Connection states used as label of metricConnections
+const (
+	connStateHandshake     = "handshake"
+	connStateAuthenticated = "authenticated"
+)
+
+var (
+	metricConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "vbgs",
+		Subsystem: "ntcp",
+		Name:      "connections",
+		Help:      "Open ntcp connections by state.",
+	}, []string{"state"})
+
+	metricPacketFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "vbgs",
+		Subsystem: "ntcp",
+		Name:      "packet_failures_total",
+		Help:      "Packets rejected before dispatching by reason.",
+	}, []string{"reason"})
+
+	metricOps = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "vbgs",
+		Name:      "ops_total",
+		Help:      "Executed ops by type and outcome (ok or error name).",
+	}, []string{"op", "outcome"})
+
+	metricOpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "vbgs",
+		Name:      "op_duration_seconds",
+		Help:      "Latency of ops including their cooldown.",
+		Buckets:   []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"op"})
+
+	metricViewers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "vbgs",
+		Name:      "viewers",
+		Help:      "Subscribed viewers by transport.",
+	}, []string{"transport"})
+
+	metricFlushSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "vbgs",
+		Subsystem: "ntfydistr",
+		Name:      "flush_size",
+		Help:      "Notifications sent per flush of a client.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 8),
+	}, []string{"user_id"})
+
+	metricQueueLength = prometheus.NewDesc(
+		"vbgs_ntfydistr_queue_length",
+		"Notifications waiting for the next flush of a client.",
+		[]string{"user_id"}, nil)
+
+	metricSubscribers = prometheus.NewDesc(
+		"vbgs_ntfydistr_subscribers",
+		"Active subscribers of a client.",
+		[]string{"user_id"}, nil)
+
+	metricKills = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "vbgs",
+		Subsystem: "game",
+		Name:      "kills_total",
+		Help:      "Kills during the round.",
+	})
+
+	metricDeaths = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "vbgs",
+		Subsystem: "game",
+		Name:      "deaths_total",
+		Help:      "Deaths during the round.",
+	})
+)
+
+// distCollector reads the current queue length and subscribers of all
+// ntfydistr clients during each scrape.
+type distCollector struct{}
+
+func (distCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- metricQueueLength
+	ch <- metricSubscribers
+}
+
+func (distCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, userID := range dist.UserIDs() {
+		c := dist.GetClient(userID)
+		ch <- prometheus.MustNewConstMetric(metricQueueLength, prometheus.GaugeValue, float64(c.QueueLength()), userID)
+		ch <- prometheus.MustNewConstMetric(metricSubscribers, prometheus.GaugeValue, float64(c.SubscriberCount()), userID)
+	}
+}
+
+func init() {
+	prometheus.MustRegister(
+		metricConnections,
+		metricPacketFailures,
+		metricOps,
+		metricOpDuration,
+		metricViewers,
+		metricFlushSize,
+		metricKills,
+		metricDeaths,
+		distCollector{},
+	)
+
+	adminMux.Handle("/metrics", promhttp.Handler())
+}
+
+// metricsInit hooks into components that are created at runtime.
+func metricsInit() {
+	dist.SetFlushHook(func(userID string, notifications int) {
+		metricFlushSize.WithLabelValues(userID).Observe(float64(notifications))
+	})
+}
+
+// metricsMiddleware counts all ops by their outcome and measures their
+// latency.
+func metricsMiddleware(next opHandlerFunc) opHandlerFunc {
+	return func(c *ntcpclient, o *op, data []byte) {
+		start := time.Now()
+		c.lastErr = nil
+
+		next(c, o, data)
+
+		outcome := "ok"
+		if c.lastErr != nil {
+			outcome = c.lastErr.Name
+		}
+		metricOps.WithLabelValues(o.Name, outcome).Inc()
+		metricOpDuration.WithLabelValues(o.Name).Observe(time.Since(start).Seconds())
+	}
+}
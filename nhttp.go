@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vikebot/vbdb"
+	"go.uber.org/zap"
+)
+
+// nhttpPrefix is the path prefix of all op endpoints. The op name follows
+// directly (e.g. `/v1/ops/move`)
+const nhttpPrefix = "/v1/ops/"
+
+// nhttpTicketTTL is the duration a verified roundticket is cached. Afterwards
+// it's verified against the database again.
+const nhttpTicketTTL = 5 * time.Minute
+
+type nhttpTicket struct {
+	userID  int
+	expires time.Time
+}
+
+// nhttpAuth caches successfully verified roundtickets so the database isn't
+// queried on every request.
+type nhttpAuth struct {
+	tickets map[string]nhttpTicket
+	baton   sync.Mutex
+}
+
+var nhttpAuthCache = nhttpAuth{
+	tickets: map[string]nhttpTicket{},
+}
+
+// cached returns the user id of the roundticket if it's cached and not
+// expired yet.
+func (a *nhttpAuth) cached(roundticket string) (userID int, ok bool) {
+	a.baton.Lock()
+	defer a.baton.Unlock()
+
+	t, ok := a.tickets[roundticket]
+	if !ok {
+		return 0, false
+	}
+	if !time.Now().Before(t.expires) {
+		delete(a.tickets, roundticket)
+		return 0, false
+	}
+	return t.userID, true
+}
+
+// Lookup returns the user id referenced by the roundticket.
+func (a *nhttpAuth) Lookup(roundticket string, ctx *zap.Logger) (userID int, status int, err *opError) {
+	if userID, ok := a.cached(roundticket); ok {
+		if guard.UserBanned(userID) {
+			return 0, http.StatusForbidden, errBanned.New("You are banned from this game.")
+		}
+		return userID, http.StatusOK, nil
+	}
+
+	v, exists, success := vbdb.RoundentryFromRoundticketCtx(roundticket, ctx)
+	if !success {
+		return 0, http.StatusInternalServerError, errInternal.New(statusInternalServerError)
+	}
+	if !exists {
+		return 0, http.StatusUnauthorized, errUnknownTicket.New("Your rounticket doesn't reference any game.")
+	}
+	if config().Battle.RoundID != v.RoundID {
+		return 0, http.StatusUnauthorized, errRoundFinished.New("Your roundticket references an already finished game.")
+	}
+	if _, ok := battle.Players[v.UserID]; !ok {
+		return 0, http.StatusUnauthorized, errNotJoined.New("Your roundticket references a user that hasn't joined this game.")
+	}
+
+	if guard.UserBanned(v.UserID) {
+		return 0, http.StatusForbidden, errBanned.New("You are banned from this game.")
+	}
+
+	a.baton.Lock()
+	a.tickets[roundticket] = nhttpTicket{
+		userID:  v.UserID,
+		expires: time.Now().Add(nhttpTicketTTL),
+	}
+	a.baton.Unlock()
+
+	return v.UserID, http.StatusOK, nil
+}
+
+// nhttpStatuses maps error codes to http statuses that differ from the
+// default of their group (see nhttpStatus).
+var nhttpStatuses = map[errCode]int{
+	errInternal:      http.StatusInternalServerError,
+	errUnknownType:   http.StatusNotFound,
+	errPacketSize:    http.StatusRequestEntityTooLarge,
+	errNotJoined:     http.StatusForbidden,
+	errBanned:        http.StatusForbidden,
+	errRoundFinished: http.StatusConflict,
+	errRoundPaused:   http.StatusConflict,
+}
+
+// nhttpStatus returns the http status of an op's response. Protocol errors
+// (1xxx) are bad requests, authentication errors (2xxx) unauthorized and game
+// errors (3xxx) unprocessable.
+func nhttpStatus(err *opError) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	if status, ok := nhttpStatuses[err.errCode]; ok {
+		return status
+	}
+
+	switch err.Code / 1000 {
+	case 1:
+		return http.StatusBadRequest
+	case 2:
+		return http.StatusUnauthorized
+	case 3:
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+func nhttpInit(start chan bool, shutdown chan bool) {
+	if config().Network.HTTP.Addr == "" {
+		log.Info("nhttp disabled. no address configured")
+		return
+	}
+
+	// bind the port right away, so the listener only reports listening once
+	// it accepts requests
+	listener, err := net.Listen("tcp", config().Network.HTTP.Addr)
+	if err != nil {
+		log.Fatal("nhttp listen failed", zap.String("addr", config().Network.HTTP.Addr), zap.Error(err))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(nhttpPrefix, nhttpHandler)
+	srv := &http.Server{
+		Addr:    config().Network.HTTP.Addr,
+		Handler: mux,
+	}
+	services.Add(1)
+	listeners.Set("nhttp", listenerReady)
+
+	go func() {
+		// Wait for start signal
+		log.Info("nhttp ready. waiting for start signal")
+		<-start
+
+		go nhttpRun(srv, listener)
+		listeners.Set("nhttp", listenerListening)
+
+		// Shutdown server when signal is received
+		<-shutdown
+		ctx, cancel := shutdownContext()
+		defer cancel()
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			log.Warn("nhttp shutdown failed", zap.Error(err))
+		}
+		listeners.Set("nhttp", listenerStopped)
+		services.Done()
+	}()
+}
+
+func nhttpRun(srv *http.Server, listener net.Listener) {
+	var srvErr error
+
+	log.Info("accepting clients on nhttp listener")
+	if config().Network.HTTP.TLS.Active {
+		srvErr = srv.ServeTLS(listener, config().Network.HTTP.TLS.Cert, config().Network.HTTP.TLS.PKey)
+	} else {
+		srvErr = srv.Serve(listener)
+	}
+
+	if srvErr != nil && srvErr != http.ErrServerClosed {
+		log.Fatal("nhttp listen failed", zap.Error(srvErr))
+	}
+}
+
+func nhttpHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := log.With(zap.String("ip", r.RemoteAddr), zap.String("transport", "http"))
+
+	if r.Method != http.MethodPost {
+		nhttpError(w, http.StatusMethodNotAllowed, "forbidden", errInvalidPacket.New("Only POST requests are allowed"), ctx)
+		return
+	}
+
+	// Handshake and subscription ops are bound to a persistent connection and
+	// therefore aren't available
+	op := strings.TrimPrefix(r.URL.Path, nhttpPrefix)
+	if o := ops.Get(op); o == nil || o.Handshake || o.Persistent {
+		nhttpError(w, http.StatusNotFound, "forbidden", errUnknownType.New("Unknown op "+strconv.Quote(op)).With("type", op), ctx)
+		return
+	}
+
+	// authenticate the request through the roundticket passed as bearer token
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		nhttpError(w, http.StatusUnauthorized, op, errNotAuth.New("Missing roundticket. Use 'Authorization: Bearer YOURROUNDTICKET'"), ctx)
+		return
+	}
+	userID, status, opErr := nhttpAuthCache.Lookup(strings.TrimPrefix(auth, "Bearer "), ctx)
+	if opErr != nil {
+		nhttpError(w, status, op, opErr, ctx)
+		return
+	}
+	ctx = ctx.With(zap.Int("user_id", userID))
+
+	// the request body is the op's obj
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<16))
+	if err != nil {
+		nhttpError(w, http.StatusBadRequest, op, errPacketSize.New("Unable to read request body").With("max", 1<<16), ctx)
+		return
+	}
+	obj := json.RawMessage("{}")
+	if len(bytes.TrimSpace(body)) > 0 {
+		obj = body
+	}
+	data, err := json.Marshal(struct {
+		Type string          `json:"type"`
+		Obj  json.RawMessage `json:"obj"`
+	}{op, obj})
+	if err != nil {
+		nhttpError(w, http.StatusBadRequest, op, errInvalidJSON.New(statusInvalidJSON), ctx)
+		return
+	}
+
+	// execute the op through the same dispatcher as ntcp. The response is
+	// written into the buffer
+	var out bytes.Buffer
+	c := &ntcpclient{
+		Out:             &out,
+		Log:             ctx,
+		Authenticated:   true,
+		UserID:          userID,
+		Player:          battle.Players[userID],
+		CurType:         op,
+		IP:              r.RemoteAddr,
+		LoginDone:       true,
+		ClienthelloDone: true,
+		AgreeconnDone:   true,
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		c.PureIP = host
+	}
+
+	// ops of the same user are serialized with the user's ntcp connection
+	lock := lockRegistry.Get(userID)
+	lock.Lock()
+	dispatch(c, data, typePacket{Type: &op})
+	lock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(nhttpStatus(c.lastErr))
+	_, err = w.Write(out.Bytes())
+	if err != nil {
+		ctx.Warn("sending http response failed", zap.Error(err))
+	}
+}
+
+// nhttpError sends err in the same format as ntcp responses with the http
+// status code.
+func nhttpError(w http.ResponseWriter, status int, curType string, err *opError, ctx *zap.Logger) {
+	buf, mErr := json.Marshal(defaultResponse{
+		Type:      curType,
+		Error:     &err.Msg,
+		ErrorInfo: newResponseError(err),
+	})
+	if mErr != nil {
+		ctx.Error("failed to marshal error response", zap.Error(mErr))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, mErr = w.Write(buf)
+	if mErr != nil {
+		ctx.Warn("sending http response failed", zap.Error(mErr))
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNhttpStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	var tests = []struct {
+		Name   string
+		Err    *opError
+		Status int
+	}{
+		{"Test01: success", nil, http.StatusOK},
+		{"Test02: protocol error", errInvalidPacket.New(""), http.StatusBadRequest},
+		{"Test03: unknown op", errUnknownType.New(""), http.StatusNotFound},
+		{"Test04: authentication error", errNotAuth.New(""), http.StatusUnauthorized},
+		{"Test05: banned", errBanned.New(""), http.StatusForbidden},
+		{"Test06: paused round", errRoundPaused.New(""), http.StatusConflict},
+		{"Test07: game error", errHasResident.New(""), http.StatusUnprocessableEntity},
+		{"Test08: internal error", errInternal.New(""), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(tt.Status, nhttpStatus(tt.Err))
+		})
+	}
+}
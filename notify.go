@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/vikebot/vbgs/vbge"
+	"go.uber.org/zap"
+)
+
+// pushDeath informs all players in ngl that p disappeared from it's location.
+func pushDeath(p *vbge.Player, ngl vbge.NotifyGroupLocated, ctx *zap.Logger) {
+	pushLeave(p, ngl, "death", ctx)
+}
+
+// pushSpawn informs all players in ngl that p (re)spawned at it's current
+// location and sends p it's new surrounding.
+func pushSpawn(p *vbge.Player, ngl vbge.NotifyGroupLocated, ctx *zap.Logger) error {
+	return pushArrive(p, ngl, "spawn", "selfspawn", ctx)
+}
+
+// pushTeleportLeave informs all players in ngl that p has been teleported
+// away from it's location.
+func pushTeleportLeave(p *vbge.Player, ngl vbge.NotifyGroupLocated, ctx *zap.Logger) {
+	pushLeave(p, ngl, "teleport", ctx)
+}
+
+// pushTeleportArrive informs all players in ngl that p has been teleported
+// to it's current location and sends p it's new surrounding.
+func pushTeleportArrive(p *vbge.Player, ngl vbge.NotifyGroupLocated, ctx *zap.Logger) error {
+	return pushArrive(p, ngl, "teleport", "selfteleport", ctx)
+}
+
+func pushLeave(p *vbge.Player, ngl vbge.NotifyGroupLocated, typ string, ctx *zap.Logger) {
+	dist.PushGroup("game", ngl.UserStringIDs(), struct {
+		GRID string `json:"grid"`
+		Type string `json:"type"`
+	}{
+		p.GRenderID,
+		typ,
+	}, ctx)
+}
+
+func pushArrive(p *vbge.Player, ngl vbge.NotifyGroupLocated, typ, selfTyp string, ctx *zap.Logger) error {
+	// create generic player response packet
+	playerResp := vbge.PlayerResp{
+		GRID:          p.GRenderID,
+		Health:        p.Health.HealthSynced(),
+		CharacterType: p.CharacterType,
+		WatchDir:      p.WatchDir,
+	}
+
+	// Inform the people around the player's new location, that he has just
+	// spawned.
+	for _, entity := range ngl {
+		if entity.Player.UserID != p.UserID {
+			// set current entities location for response packet
+			playerResp.Location = entity.ARLoc
+
+			// send notification
+			dist.GetClient(strconv.Itoa(entity.Player.UserID)).Push("game", struct {
+				GRID       string          `json:"grid"`
+				Type       string          `json:"type"`
+				PlayerInfo vbge.PlayerResp `json:"playerinfo"`
+			}{
+				p.GRenderID,
+				typ,
+				playerResp,
+			}, ctx)
+		}
+	}
+
+	// Inform the player itself that he has respawned
+	playerMapentity, err := vbge.GetViewableMapentity(vbge.RenderWidth, vbge.RenderHeight, p.UserID, battle, false)
+	if err != nil {
+		return err
+	}
+	dist.GetClient(strconv.Itoa(p.UserID)).Push("game", struct {
+		GRID            string                  `json:"grid"`
+		Type            string                  `json:"type"`
+		Loc             *vbge.ARLocation        `json:"loc"`
+		PlayerMapEntity *vbge.ViewableMapentity `json:"playermapentity"`
+	}{
+		p.GRenderID,
+		selfTyp,
+		p.Location.ToARLocation(),
+		playerMapentity,
+	}, ctx)
+
+	return nil
+}
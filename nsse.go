@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vikebot/vbcore"
+	"github.com/vikebot/vbgs/pkg/ntfydistr"
+	"go.uber.org/zap"
+)
+
+// nsseEndpoint is the path of the Server-Sent Events endpoint on the nws
+// server. The watchtoken is passed as `watchtoken` query parameter, because
+// browsers' EventSource can't set custom headers.
+const nsseEndpoint = "/sse"
+
+func nsseHandler(w http.ResponseWriter, r *http.Request) {
+	sserqid := strings.ToLower(vbcore.FastRandomString(16))
+	ctx := log.With(zap.String("sseid", sserqid))
+
+	ctx.Info("sse connected", zap.String("ip", r.RemoteAddr))
+	defer ctx.Info("closing sse connection", zap.String("ip", r.RemoteAddr))
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		ctx.Error("response writer doesn't support flushing")
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	userID, errMsg := nwsVerifyWatchtoken(r.URL.Query().Get("watchtoken"), ctx)
+	if errMsg != "" {
+		http.Error(w, errMsg, http.StatusUnauthorized)
+		return
+	}
+	ctx = ctx.With(zap.Int("user_id", userID))
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	if config().Network.WS.ValidOrigin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", "https://"+config().Network.WS.ValidOrigin)
+	}
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	receiver := &ntfySSEReceiver{
+		w:      w,
+		f:      flusher,
+		userID: userID,
+		log:    ctx,
+	}
+
+	// keep proxies from closing idle streams. The handler must not return
+	// before the keepalive goroutine stopped writing
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	if interval := config().Network.WS.Timeouts.SSEKeepalive.Duration; interval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			receiver.keepalive(interval, stop)
+		}()
+	}
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+
+	// the subscription is cancelled as soon as the viewer closes the request
+	metricViewers.WithLabelValues("sse").Inc()
+	defer metricViewers.WithLabelValues("sse").Dec()
+
+	dist.GetClient(strconv.Itoa(userID)).SubUntil(receiver, r.Context().Done(), ctx)
+}
+
+// ntfySSEReceiver streams the batched notification JSON as Server-Sent
+// Events. Every batch is sent as a single `data` field.
+type ntfySSEReceiver struct {
+	w      http.ResponseWriter
+	f      http.Flusher
+	userID int
+	log    *zap.Logger
+	wSync  sync.Mutex
+}
+
+func (r *ntfySSEReceiver) Init(initClient *ntfydistr.Client) {
+	r.log.Debug("initing sse subscription for user")
+
+	initViewer(initClient, r.userID, r.log)
+}
+
+func (r *ntfySSEReceiver) Write(notf []byte) (disconnected bool, err error) {
+	return r.write("data: %s\n\n", notf)
+}
+
+func (r *ntfySSEReceiver) write(format string, a ...interface{}) (disconnected bool, err error) {
+	r.wSync.Lock()
+	defer r.wSync.Unlock()
+
+	_, err = fmt.Fprintf(r.w, format, a...)
+	if err != nil {
+		// writes only fail if the remote party is gone
+		return true, err
+	}
+	r.f.Flush()
+
+	return false, nil
+}
+
+func (r *ntfySSEReceiver) keepalive(interval time.Duration, done <-chan struct{}) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-tick.C:
+			// comment lines are ignored by EventSource clients
+			if disconnected, _ := r.write(": keepalive\n\n"); disconnected {
+				return
+			}
+		}
+	}
+}
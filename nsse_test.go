@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vikebot/vbcore"
+	"github.com/vikebot/vbgs/vbge"
+	"go.uber.org/zap"
+)
+
+// withViewerBattle serves viewers of user 1 from a fresh battle while fn
+// runs. The only valid watchtoken is "valid".
+func withViewerBattle(t *testing.T, fn func()) {
+	m := vbge.NewMapEntity(vbge.MapWidth, vbge.MapHeight)
+	p, err := vbge.NewPlayerWithSpawn(1, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prevBattle, prevLookup, prevUsernames := battle, nwsRoundentryFromWatchtoken, usernamesFromRoundID
+	defer func() {
+		battle, nwsRoundentryFromWatchtoken, usernamesFromRoundID = prevBattle, prevLookup, prevUsernames
+	}()
+
+	battle = &vbge.Battle{
+		Map:     m,
+		Players: map[int]*vbge.Player{1: p},
+	}
+	nwsRoundentryFromWatchtoken = func(watchtoken string, ctx *zap.Logger) (*vbcore.RoundentryVerification, bool, bool) {
+		if watchtoken != "valid" {
+			return nil, false, true
+		}
+		return &vbcore.RoundentryVerification{UserID: 1, RoundID: config().Battle.RoundID}, true, true
+	}
+
+	usernamesFromRoundID = func(roundID int) (map[int]string, bool) {
+		return map[int]string{1: "viewer"}, true
+	}
+
+	fn()
+}
+
+// sseLines returns a channel receiving all non-empty lines of the stream.
+func sseLines(resp *http.Response) <-chan string {
+	lines := make(chan string, 16)
+	go func() {
+		defer close(lines)
+		s := bufio.NewScanner(resp.Body)
+		s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for s.Scan() {
+			if s.Text() != "" {
+				lines <- s.Text()
+			}
+		}
+	}()
+	return lines
+}
+
+// nextLine returns the next line starting with prefix. Other lines are
+// skipped.
+func nextLine(lines <-chan string, prefix string) (string, bool) {
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case l, ok := <-lines:
+			if !ok {
+				return "", false
+			}
+			if strings.HasPrefix(l, prefix) {
+				return l, true
+			}
+		case <-timeout:
+			return "", false
+		}
+	}
+}
+
+func TestNsseHandlerWatchtoken(t *testing.T) {
+	var tests = []struct {
+		Name       string
+		Method     string
+		Watchtoken string
+		Status     int
+	}{
+		{"Test01: unknown watchtoken", http.MethodGet, "invalid", http.StatusUnauthorized},
+		{"Test02: missing watchtoken", http.MethodGet, "", http.StatusUnauthorized},
+		{"Test03: wrong method", http.MethodPost, "valid", http.StatusMethodNotAllowed},
+	}
+
+	withViewerBattle(t, func() {
+		srv := httptest.NewServer(http.HandlerFunc(nsseHandler))
+		defer srv.Close()
+
+		for _, tt := range tests {
+			t.Run(tt.Name, func(t *testing.T) {
+				assert := assert.New(t)
+
+				req, err := http.NewRequest(tt.Method, srv.URL+nsseEndpoint+"?watchtoken="+tt.Watchtoken, nil)
+				if !assert.NoError(err) {
+					return
+				}
+				resp, err := http.DefaultClient.Do(req)
+				if !assert.NoError(err) {
+					return
+				}
+				defer resp.Body.Close()
+
+				assert.Equal(tt.Status, resp.StatusCode)
+				_, err = ioutil.ReadAll(resp.Body)
+				assert.NoError(err)
+				assert.Zero(dist.GetClient("1").SubscriberCount())
+			})
+		}
+	})
+}
+
+func TestNsseHandlerStream(t *testing.T) {
+	assert := assert.New(t)
+
+	conf := *config()
+	conf.Network.WS.Timeouts.SSEKeepalive.Duration = 20 * time.Millisecond
+	prev := config()
+	setConfig(&conf)
+	defer setConfig(prev)
+
+	withViewerBattle(t, func() {
+		handled := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer close(handled)
+			nsseHandler(w, r)
+		}))
+		defer srv.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		req, err := http.NewRequest(http.MethodGet, srv.URL+nsseEndpoint+"?watchtoken=valid", nil)
+		if !assert.NoError(err) {
+			return
+		}
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if !assert.NoError(err) {
+			return
+		}
+		defer resp.Body.Close()
+
+		assert.Equal(http.StatusOK, resp.StatusCode)
+		assert.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+		lines := sseLines(resp)
+
+		// the init payload is the first event
+		l, ok := nextLine(lines, "data: ")
+		if assert.True(ok) {
+			assert.Contains(l, `"type":"initial"`)
+		}
+
+		// live notifications follow in the same framing
+		ntfy := dist.GetClient("1")
+		if !assert.True(eventually(func() bool { return ntfy.SubscriberCount() == 1 })) {
+			return
+		}
+		ntfy.Push("game", struct {
+			Type string `json:"type"`
+		}{"testsse"}, log)
+		l, ok = nextLine(lines, "data: ")
+		if assert.True(ok) {
+			assert.Contains(l, `"type":"testsse"`)
+		}
+
+		// idle streams get keepalive comments
+		_, ok = nextLine(lines, ": keepalive")
+		assert.True(ok)
+
+		// disconnecting stops the handler and removes the subscription
+		cancel()
+		select {
+		case <-handled:
+		case <-time.After(time.Second):
+			assert.Fail("handler didn't return after disconnect")
+		}
+		assert.True(eventually(func() bool { return ntfy.SubscriberCount() == 0 }))
+	})
+}
@@ -2,98 +2,233 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"net"
 	"strconv"
 	"strings"
+	"time"
 
 	"go.uber.org/zap"
 )
 
+var errPacketTooLarge = errors.New("ntcp: packet exceeds maximum size")
+
 func ntcpInit(start chan bool, shutdown chan bool) {
-	listener, err := net.Listen("tcp", config.Network.TCP.Addr)
+	guard = newNtcpGuard()
+
+	listener, err := net.Listen("tcp", config().Network.TCP.Addr)
 	if err != nil {
-		log.Fatal("ntcp listen failed", zap.String("addr", config.Network.TCP.Addr), zap.Error(err))
+		log.Fatal("ntcp listen failed", zap.String("addr", config().Network.TCP.Addr), zap.Error(err))
 	}
 
+	services.Add(1)
+	listeners.Set("ntcp", listenerReady)
 	go func() {
 		// Wait for start signal
 		log.Info("ntcp ready. waiting for start signal")
 		<-start
 
-		go ntcpRun(listener)
-
-		// Shutdown listener as soon as we get signal from master
+		running := make(chan struct{})
+		go func() {
+			defer close(running)
+			ntcpRun(listener, shutdown)
+		}()
+		listeners.Set("ntcp", listenerListening)
+
+		// Shutdown listener as soon as we get signal from master. Open
+		// connections are closed during the shutdown after the bots have been
+		// informed. Each connection is tracked by services itself, so the
+		// service is only done after they drained
 		<-shutdown
 		err = listener.Close()
 		if err != nil {
 			log.Warn("ntcp close failed", zap.Error(err))
 		}
+		<-running
+		listeners.Set("ntcp", listenerStopped)
+		services.Done()
 	}()
 }
 
-func ntcpRun(listener net.Listener) {
+func ntcpRun(listener net.Listener, shutdown chan bool) {
 	log.Info("accepting clients on ntcp listener")
 
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			select {
+			case <-shutdown:
+				log.Info("stopped accepting clients on ntcp listener")
+				return
+			default:
+			}
 			log.Warn("ntcp accept failed", zap.Error(err))
 			continue
 		}
 
-		go func(c net.Conn) {
-			defer c.Close()
+		// let the OS detect dead peers on idle connections
+		if tcpConn, ok := conn.(*net.TCPConn); ok && config().Network.TCP.Timeouts.Keepalive.Duration > 0 {
+			err = tcpConn.SetKeepAlive(true)
+			if err == nil {
+				err = tcpConn.SetKeepAlivePeriod(config().Network.TCP.Timeouts.Keepalive.Duration)
+			}
+			if err != nil {
+				log.Warn("ntcp enabling keepalive failed", zap.Error(err))
+			}
+		}
+
+		services.Add(1)
+		go func(conn net.Conn) {
+			defer services.Done()
+			defer conn.Close()
+
+			ctx := log.With(zap.String("ip", conn.RemoteAddr().String()))
+
+			c := newNtcpclient(conn, ctx)
+			defer close(c.done)
 
-			ctx := log.With(zap.String("ip", c.RemoteAddr().String()))
+			// reject banned ips and ips exceeding their connection limit
+			if err := guard.Acquire(c); err != nil {
+				ctx.Warn("rejected ntcp connection", zap.Error(err))
+				return
+			}
+			defer guard.Release(c)
 
+			// c.Log contains the user's context as soon as it's known
 			defer func() {
 				recoverd := recover()
 				switch rval := recoverd.(type) {
 				case nil:
 					return
 				case error:
-					ctx.Error("recoverd from panic",
+					c.Log.Error("recoverd from panic",
 						zap.Error(rval),
+						zap.String("op", c.CurType),
 						zap.Stack("recoverd_stack"))
 				default:
-					ctx.Error("recoverd from panic", zap.Any("unknown_err", rval))
+					c.Log.Error("recoverd from panic",
+						zap.Any("unknown_err", rval),
+						zap.String("op", c.CurType),
+						zap.Stack("recoverd_stack"))
 				}
 			}()
 
-			ntcp(c, ctx)
+			ntcp(c)
 		}(conn)
 	}
 }
 
-func ntcp(conn net.Conn, ctx *zap.Logger) {
-	c := newNtcpclient(conn.RemoteAddr(), conn, ctx)
+func ntcp(c *ntcpclient) {
+	conn := c.Conn
 	buf := bufio.NewReader(conn)
 
 	c.Log.Info("connected")
 
+	state := connStateHandshake
+	metricConnections.WithLabelValues(state).Inc()
+	defer func() {
+		metricConnections.WithLabelValues(state).Dec()
+	}()
+
+	// unauthenticated clients must finish the handshake till this deadline
+	var handshakeDeadline time.Time
+	if config().Network.TCP.Timeouts.Handshake.Duration > 0 {
+		handshakeDeadline = time.Now().Add(config().Network.TCP.Timeouts.Handshake.Duration)
+	}
+
 	for {
-		data, err := buf.ReadBytes('\n')
+		// peers that stay silent longer than the read timeout are considered
+		// dead. Bots can use the `ping` op to keep idle connections alive
+		var deadline time.Time
+		if config().Network.TCP.Timeouts.Read.Duration > 0 {
+			deadline = time.Now().Add(config().Network.TCP.Timeouts.Read.Duration)
+		}
+		if !c.Authenticated && !handshakeDeadline.IsZero() && (deadline.IsZero() || handshakeDeadline.Before(deadline)) {
+			deadline = handshakeDeadline
+		}
+		err := conn.SetReadDeadline(deadline)
+		if err != nil {
+			c.Log.Warn("setting read deadline failed", zap.Error(err))
+		}
+
+		data, err := readPacket(buf, config().Network.TCP.Limits.MaxPacketSize)
 		if err != nil {
+			if err == errPacketTooLarge {
+				c.Log.Warn("packet exceeds maximum size. closing connection",
+					zap.Int("max_packet_size", config().Network.TCP.Limits.MaxPacketSize))
+				c.CurType = "forbidden"
+				c.RespondErr(errPacketSize.New("Invalid packet. Maximum packet size exceeded").
+					With("max", config().Network.TCP.Limits.MaxPacketSize))
+				c.violation("packet too large")
+				disconnect(c)
+				return
+			}
+
 			if strings.HasSuffix(err.Error(), "An existing connection was forcibly closed by the remote host.") || err.Error() == "EOF" {
 				disconnect(c)
 				return
 			}
 
+			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+				if !c.Authenticated && !handshakeDeadline.IsZero() && !time.Now().Before(handshakeDeadline) {
+					c.Log.Warn("handshake not completed in time. closing connection")
+				} else {
+					c.Log.Info("read timeout exceeded. closing idle connection")
+				}
+				disconnect(c)
+				return
+			}
+
 			if _, ok := err.(*net.OpError); ok {
 				disconnect(c)
 				return
 			}
 
 			c.Log.Warn("unknown error during ntcp read", zap.Error(err))
+			disconnect(c)
 			return
 		}
 
-		packetHandler(c, data[:len(data)-1])
+		packetHandler(c, data)
+
+		if state == connStateHandshake && c.Authenticated {
+			metricConnections.WithLabelValues(state).Dec()
+			state = connStateAuthenticated
+			metricConnections.WithLabelValues(state).Inc()
+		}
+	}
+}
+
+// readPacket reads a single '\n' terminated packet from buf. The returned
+// slice doesn't include the delimiter. If max is greater than zero and the
+// packet exceeds max bytes errPacketTooLarge is returned.
+func readPacket(buf *bufio.Reader, max int) ([]byte, error) {
+	var data []byte
+	for {
+		chunk, err := buf.ReadSlice('\n')
+		if max > 0 && len(data)+len(chunk) > max+1 {
+			return nil, errPacketTooLarge
+		}
+		data = append(data, chunk...)
+
+		switch err {
+		case nil:
+			return data[:len(data)-1], nil
+		case bufio.ErrBufferFull:
+			continue
+		default:
+			return nil, err
+		}
 	}
 }
 
 func disconnect(c *ntcpclient) {
 	c.Log.Info("disconnected")
-	dist.GetClient(strconv.Itoa(c.UserID)).PushInfo(false, c.IP, c.SDK, c.SDKLink, c.OS, c.Log)
-	ntcpRegistry.Delete(c)
+	c.stopEvents()
+
+	// only inform the user about the disconnect if no other connection has
+	// taken over the session in the meantime
+	if ntcpRegistry.Delete(c) {
+		dist.GetClient(strconv.Itoa(c.UserID)).PushInfo(false, c.IP, c.SDK, c.SDKLink, c.OS, c.Log)
+	}
 }
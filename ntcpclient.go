@@ -2,9 +2,11 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/vikebot/vbcore"
 	"github.com/vikebot/vbgs/vbge"
@@ -12,6 +14,7 @@ import (
 )
 
 type ntcpclient struct {
+	Conn          net.Conn
 	Out           io.Writer
 	Log           *zap.Logger
 	Authenticated bool
@@ -33,19 +36,49 @@ type ntcpclient struct {
 
 	StartPc uint32
 	Pc      uint32
+
+	// lastErr is the error of the last response. It's used to determine the
+	// outcome of an op
+	lastErr *opError
+
+	// audit is the record of the currently audited op
+	audit *auditRecord
+
+	// batch receives the response of the currently executed op during a batch
+	// execution instead of sending it
+	batch *batchResult
+
+	// events contains the game events the client subscribed to. A nil map
+	// means the client hasn't subscribed. eventsDone cancels the current
+	// subscription. Both are guarded by eventsSync
+	events     map[string]bool
+	eventsDone chan struct{}
+	eventsSync sync.Mutex
+
+	writeSync sync.Mutex
+
+	// done is closed as soon as the goroutine serving the connection stopped
+	done chan struct{}
 }
 
-func newNtcpclient(ip net.Addr, w io.Writer, ctx *zap.Logger) *ntcpclient {
-	pureip := ip.String()
-	if addr, ok := ip.(*net.TCPAddr); ok {
-		pureip = addr.IP.String()
+// pureIP returns the ip of addr without it's port (if addr is a TCP address).
+func pureIP(addr net.Addr) string {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP.String()
 	}
+	return addr.String()
+}
+
+func newNtcpclient(conn net.Conn, ctx *zap.Logger) *ntcpclient {
+	ip := conn.RemoteAddr()
 	return &ntcpclient{
 		Log:     ctx,
 		IP:      ip.String(),
-		PureIP:  pureip,
-		Out:     w,
+		PureIP:  pureIP(ip),
+		Conn:    conn,
+		Out:     conn,
 		CurType: "unknown",
+		done:    make(chan struct{}),
 	}
 }
 
@@ -56,15 +89,19 @@ type typePacket struct {
 }
 
 type defaultResponse struct {
-	Type  string  `json:"type"`
-	Pc    *uint32 `json:"pc,omitempty"`
-	Error *string `json:"error"`
+	Type      string         `json:"type"`
+	Pc        *uint32        `json:"pc,omitempty"`
+	Error     *string        `json:"error"`
+	ErrorInfo *responseError `json:"errorinfo,omitempty"`
 }
 
-func newDefaultResponse(c *ntcpclient, err *string) defaultResponse {
+func newDefaultResponse(c *ntcpclient, err *opError) defaultResponse {
 	dr := defaultResponse{
-		Type:  c.CurType,
-		Error: err,
+		Type:      c.CurType,
+		ErrorInfo: newResponseError(err),
+	}
+	if err != nil {
+		dr.Error = &err.Msg
 	}
 	if c.IsEncrypted {
 		c.Pc++
@@ -94,10 +131,23 @@ func newDefaultObjResponse(c *ntcpclient, d interface{}) defaultObjResponse {
 }
 
 func (c *ntcpclient) MgmtWrite(d interface{}) {
+	err := c.write(d, zap.Uint32("seqnr", c.Pc-c.StartPc))
+	if err != nil {
+		c.Log.Warn("sending failed", zap.Error(err))
+	}
+}
+
+// Push sends d as server-push packet. Pushes don't take part in the pc
+// sequence and may be sent concurrently to responses.
+func (c *ntcpclient) Push(d interface{}) error {
+	return c.write(d, zap.Bool("push", true))
+}
+
+func (c *ntcpclient) write(d interface{}, seq zap.Field) error {
 	buf, err := json.Marshal(d)
 	if err != nil {
 		c.Log.Warn("failed to marshal interface", zap.Error(err))
-		return
+		return nil
 	}
 
 	// Precreate debug message (only print it if encryption succeeds)
@@ -108,38 +158,100 @@ func (c *ntcpclient) MgmtWrite(d interface{}) {
 		cipher, err := c.Crypt.EncryptBase64(buf)
 		if err != nil {
 			c.Log.Error("encrypting buffer failed", zap.Error(err))
-			return
+			return nil
 		}
 		buf = cipher
 	}
 
 	c.Log.Debug("sent",
 		zap.String("packet", pkt),
-		zap.Uint32("seqnr", c.Pc-c.StartPc))
+		seq)
+
+	c.writeSync.Lock()
+	defer c.writeSync.Unlock()
+
+	// don't let a peer that stopped reading block us forever
+	if c.Conn != nil && config().Network.TCP.Timeouts.Write.Duration > 0 {
+		err = c.Conn.SetWriteDeadline(time.Now().Add(config().Network.TCP.Timeouts.Write.Duration))
+		if err != nil {
+			c.Log.Warn("setting write deadline failed", zap.Error(err))
+		}
+	}
 
 	buf = append(buf, '\n')
 	_, err = c.Out.Write(buf)
+	return err
+}
+
+// collect stores the response as result of the batch's current op. It reports
+// whether a batch is active and the response therefore mustn't be sent.
+func (c *ntcpclient) collect(err *opError, d interface{}) bool {
+	c.lastErr = err
+	if c.audit != nil {
+		c.audit.respond(c.CurType, err, d)
+	}
+	if c.batch == nil {
+		return false
+	}
+
+	r := batchResult{
+		Type:      c.CurType,
+		ErrorInfo: newResponseError(err),
+		Obj:       d,
+	}
 	if err != nil {
-		c.Log.Warn("sending failed", zap.Error(err))
+		r.Error = &err.Msg
 	}
+	*c.batch = r
+	return true
 }
 
 func (c *ntcpclient) RespondNil() {
+	if c.collect(nil, nil) {
+		return
+	}
 	c.MgmtWrite(newDefaultResponse(c, nil))
 }
 
-func (c *ntcpclient) Respond(errorText string) {
-	c.MgmtWrite(newDefaultResponse(c, &errorText))
-}
-
-func (c *ntcpclient) RespondFmt(format string, a ...interface{}) {
-	c.Respond(fmt.Sprintf(format, a...))
+// RespondErr sends the error's message together with it's catalogue entry to
+// the client.
+func (c *ntcpclient) RespondErr(err *opError) {
+	if c.collect(err, nil) {
+		return
+	}
+	c.MgmtWrite(newDefaultResponse(c, err))
 }
 
 func (c *ntcpclient) RespondObj(d interface{}) {
+	if c.collect(nil, d) {
+		return
+	}
 	c.MgmtWrite(newDefaultObjResponse(c, d))
 }
 
+// Kick closes the underlying connection of the client. The read loop in
+// `ntcp` will notice the closed connection and clean up afterwards.
+func (c *ntcpclient) Kick(reason string) {
+	c.Log.Info("kicking client", zap.String("reason", reason))
+	if c.Conn == nil {
+		return
+	}
+
+	// the connection could already be closed by a concurrent kick
+	err := c.Conn.Close()
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		c.Log.Warn("closing kicked connection failed", zap.Error(err))
+	}
+}
+
+// wait blocks till the goroutine serving the client's connection stopped.
+// Afterwards the client's state can be read safely from other goroutines.
+func (c *ntcpclient) wait() {
+	if c.done != nil {
+		<-c.done
+	}
+}
+
 func (c *ntcpclient) InitAes(key []byte) error {
 	cs, err := vbcore.NewCryptoService(key)
 	if err != nil {
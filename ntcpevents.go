@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+
+	"github.com/vikebot/vbgs/pkg/ntfydistr"
+	"go.uber.org/zap"
+)
+
+// gameEvents contains all game event types a bot can subscribe to. They are
+// the same events websocket viewers receive for the bot's user.
+var gameEvents = map[string]bool{
+	"health":       true,
+	"death":        true,
+	"spawn":        true,
+	"selfspawn":    true,
+	"teleport":     true,
+	"selfteleport": true,
+	"attack":       true,
+	"move":         true,
+	"rotate":       true,
+	"scout":        true,
+	"environment":  true,
+	"defend":       true,
+	"undefend":     true,
+}
+
+type pushPacket struct {
+	Type string      `json:"type"`
+	Push bool        `json:"push"`
+	Obj  interface{} `json:"obj"`
+}
+
+type gameEvent struct {
+	Type  string          `json:"type"`
+	Unixn int64           `json:"unixn"`
+	Obj   json.RawMessage `json:"obj"`
+}
+
+type eventsPush struct {
+	Events []gameEvent `json:"events"`
+}
+
+// subscribeEvents subscribes the client for all game events contained in
+// events. A previously existing subscription is replaced.
+func (c *ntcpclient) subscribeEvents(events map[string]bool) {
+	c.eventsSync.Lock()
+	defer c.eventsSync.Unlock()
+
+	c.stopEventsLocked()
+	c.events = events
+	c.eventsDone = make(chan struct{})
+
+	go dist.GetClient(strconv.Itoa(c.UserID)).SubUntil(ntfyNtcpReceiver{
+		c:      c,
+		events: events,
+	}, c.eventsDone, c.Log)
+}
+
+// unsubscribeEvents cancels the current event subscription of the client (if
+// any).
+func (c *ntcpclient) unsubscribeEvents() {
+	c.eventsSync.Lock()
+	defer c.eventsSync.Unlock()
+
+	c.stopEventsLocked()
+	c.events = nil
+}
+
+// stopEvents stops pushing events to the client but keeps the subscribed
+// events, so a resumed session can continue the subscription.
+func (c *ntcpclient) stopEvents() {
+	c.eventsSync.Lock()
+	defer c.eventsSync.Unlock()
+
+	c.stopEventsLocked()
+}
+
+func (c *ntcpclient) stopEventsLocked() {
+	if c.eventsDone == nil {
+		return
+	}
+
+	close(c.eventsDone)
+	c.eventsDone = nil
+}
+
+// subscribedEvents returns the game events the client subscribed to or nil.
+func (c *ntcpclient) subscribedEvents() map[string]bool {
+	c.eventsSync.Lock()
+	defer c.eventsSync.Unlock()
+
+	return c.events
+}
+
+type ntfyNtcpReceiver struct {
+	c      *ntcpclient
+	events map[string]bool
+}
+
+func (r ntfyNtcpReceiver) Init(initClient *ntfydistr.Client) {
+	// bots don't need any initial state. they can query it via ops
+}
+
+func (r ntfyNtcpReceiver) Write(notf []byte) (disconnected bool, err error) {
+	var notfs []struct {
+		Type  string          `json:"type"`
+		Obj   json.RawMessage `json:"obj"`
+		Unixn int64           `json:"unixn"`
+	}
+	err = json.Unmarshal(notf, &notfs)
+	if err != nil {
+		return false, err
+	}
+
+	// filter for game events the client subscribed to
+	var events []gameEvent
+	for _, n := range notfs {
+		if n.Type != "game" {
+			continue
+		}
+
+		var obj struct {
+			Type string `json:"type"`
+		}
+		err = json.Unmarshal(n.Obj, &obj)
+		if err != nil || !r.events[obj.Type] {
+			continue
+		}
+
+		events = append(events, gameEvent{
+			Type:  obj.Type,
+			Unixn: n.Unixn,
+			Obj:   n.Obj,
+		})
+	}
+	if len(events) == 0 {
+		return false, nil
+	}
+
+	err = r.c.Push(pushPacket{
+		Type: "event",
+		Push: true,
+		Obj: eventsPush{
+			Events: events,
+		},
+	})
+	if err == nil {
+		return false, nil
+	}
+
+	// check if the remote party disconnected
+	if _, ok := err.(*net.OpError); ok {
+		return true, err
+	}
+
+	r.c.Log.Warn("pushing events failed", zap.Error(err))
+	return false, err
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pushedEvents returns the types of all events pushed to c since the last
+// call. Responses are ignored.
+func pushedEvents(assert *assert.Assertions, c *testClient) []string {
+	var types []string
+	for _, r := range c.responses() {
+		if r["push"] != true {
+			continue
+		}
+		assert.Equal("event", r["type"])
+		for _, e := range r["obj"].(map[string]interface{})["events"].([]interface{}) {
+			e := e.(map[string]interface{})
+			assert.Equal(e["type"], e["obj"].(map[string]interface{})["type"])
+			assert.NotZero(e["unixn"])
+			types = append(types, e["type"].(string))
+		}
+	}
+	return types
+}
+
+// eventually polls cond till it's true or a second passed.
+func eventually(cond func() bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return cond()
+}
+
+func TestNtfyNtcpReceiverWrite(t *testing.T) {
+	var tests = []struct {
+		Name   string
+		Notf   string
+		Events []string
+	}{
+		{"Test01: matched and unmatched events",
+			`[{"type":"game","unixn":1,"obj":{"type":"move"}},{"type":"game","unixn":2,"obj":{"type":"rotate"}}]`,
+			[]string{"move"}},
+		{"Test02: only unmatched events",
+			`[{"type":"game","unixn":1,"obj":{"type":"rotate"}}]`,
+			nil},
+		{"Test03: other notification types",
+			`[{"type":"chat","unixn":1,"obj":{"type":"move"}},{"type":"game","unixn":2,"obj":{"type":"move"}}]`,
+			[]string{"move"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			c := newTestClient("10.0.6.1")
+			r := ntfyNtcpReceiver{
+				c:      c.ntcpclient,
+				events: map[string]bool{"move": true},
+			}
+
+			disconnected, err := r.Write([]byte(tt.Notf))
+			assert.NoError(err)
+			assert.False(disconnected)
+			assert.Equal(tt.Events, pushedEvents(assert, c))
+		})
+	}
+}
+
+func TestNtcpEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	c := newTestClient("10.0.6.2")
+	c.UserID = 3
+	c.Authenticated = true
+	ntfy := dist.GetClient("3")
+
+	// push sends a matched and an unmatched game event to the user
+	push := func() {
+		ntfy.Push("game", struct {
+			Type string `json:"type"`
+		}{"move"}, c.Log)
+		ntfy.Push("game", struct {
+			Type string `json:"type"`
+		}{"rotate"}, c.Log)
+	}
+
+	c.send(`{"type":"subscribe","obj":{"events":["move"]}}`)
+	rs := c.responses()
+	if assert.Len(rs, 1) {
+		assert.Equal([]interface{}{"move"}, rs[0]["obj"].(map[string]interface{})["events"])
+	}
+	if !assert.True(eventually(func() bool { return ntfy.SubscriberCount() == 1 })) {
+		return
+	}
+
+	push()
+	var events []string
+	assert.True(eventually(func() bool {
+		events = append(events, pushedEvents(assert, c)...)
+		return len(events) > 0
+	}))
+	assert.Equal([]string{"move"}, events)
+
+	// after unsubscribing nothing is pushed anymore
+	c.send(`{"type":"unsubscribe","obj":{}}`)
+	assert.Len(c.responses(), 1)
+	assert.Nil(c.subscribedEvents())
+	if !assert.True(eventually(func() bool { return ntfy.SubscriberCount() == 0 })) {
+		return
+	}
+
+	push()
+	assert.True(eventually(func() bool { return ntfy.QueueLength() == 0 }))
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(pushedEvents(assert, c))
+}
+
+func TestNtcpEventsStop(t *testing.T) {
+	assert := assert.New(t)
+
+	c := newTestClient("10.0.6.3")
+	c.UserID = 2
+	ntfy := dist.GetClient("2")
+
+	c.subscribeEvents(map[string]bool{"move": true})
+	if !assert.True(eventually(func() bool { return ntfy.SubscriberCount() == 1 })) {
+		return
+	}
+
+	// stopping keeps the subscribed events for a resumed session
+	c.stopEvents()
+	assert.True(eventually(func() bool { return ntfy.SubscriberCount() == 0 }))
+	assert.Equal(map[string]bool{"move": true}, c.subscribedEvents())
+
+	// stopping twice or unsubscribing afterwards is safe
+	c.stopEvents()
+	c.unsubscribeEvents()
+	assert.Nil(c.subscribedEvents())
+}
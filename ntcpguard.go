@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// guardPruneInterval is the minimum interval between two prunes of the
+// guard's expired violations and bans.
+const guardPruneInterval = time.Minute
+
+// ntcpGuard protects the ntcp listener from abusive clients. It limits the
+// amount of concurrent connections per IP and temporarily bans IPs after
+// repeated protocol violations. Additionally admins can ban single users.
+type ntcpGuard struct {
+	conns      map[string]map[*ntcpclient]bool
+	violations map[string][]time.Time
+	bans       map[string]time.Time
+	userBans   map[int]time.Time
+	pruned     time.Time
+	now        func() time.Time
+	baton      sync.Mutex
+}
+
+func newNtcpGuard() *ntcpGuard {
+	return &ntcpGuard{
+		conns:      map[string]map[*ntcpclient]bool{},
+		violations: map[string][]time.Time{},
+		bans:       map[string]time.Time{},
+		userBans:   map[int]time.Time{},
+		now:        time.Now,
+	}
+}
+
+// Acquire registers the new connection of c. An error is returned if c's ip
+// is currently banned or already has the maximum amount of concurrent
+// connections open. Only if Acquire succeeds Release must be called after the
+// connection closed.
+func (g *ntcpGuard) Acquire(c *ntcpclient) error {
+	g.baton.Lock()
+	defer g.baton.Unlock()
+
+	now := g.now()
+	g.prune(now)
+
+	if until, ok := g.bans[c.PureIP]; ok {
+		if now.Before(until) {
+			return fmt.Errorf("ip banned until %s", until.UTC().Format(time.RFC3339))
+		}
+		delete(g.bans, c.PureIP)
+	}
+
+	max := config().Network.TCP.Limits.MaxConnsPerIP
+	if max > 0 && len(g.conns[c.PureIP]) >= max {
+		return fmt.Errorf("maximum of %d concurrent connections reached", max)
+	}
+	if g.conns[c.PureIP] == nil {
+		g.conns[c.PureIP] = map[*ntcpclient]bool{}
+	}
+	g.conns[c.PureIP][c] = true
+
+	return nil
+}
+
+// Release unregisters the connection of c previously acquired.
+func (g *ntcpGuard) Release(c *ntcpclient) {
+	g.baton.Lock()
+	defer g.baton.Unlock()
+
+	delete(g.conns[c.PureIP], c)
+	if len(g.conns[c.PureIP]) == 0 {
+		delete(g.conns, c.PureIP)
+	}
+}
+
+// Violation records a protocol violation for ip. Only violations inside the
+// configured window are counted. If their amount reaches the configured
+// threshold the ip gets banned, all of it's open connections are closed and
+// Violation returns true.
+func (g *ntcpGuard) Violation(ip string) (banned bool) {
+	limits := config().Network.TCP.Limits.Ban
+	if limits.Violations <= 0 {
+		return false
+	}
+
+	g.baton.Lock()
+	now := g.now()
+	g.prune(now)
+
+	vs := append(g.recent(g.violations[ip], now), now)
+	if len(vs) < limits.Violations {
+		g.violations[ip] = vs
+		g.baton.Unlock()
+		return false
+	}
+
+	delete(g.violations, ip)
+	kick := g.ban(ip, now.Add(limits.Duration.Duration))
+	g.baton.Unlock()
+
+	for _, c := range kick {
+		c.Kick("ip banned after too many protocol violations")
+	}
+	return true
+}
+
+// recent returns the violations that are still inside the configured window.
+// The guard's lock must be held.
+func (g *ntcpGuard) recent(vs []time.Time, now time.Time) []time.Time {
+	window := config().Network.TCP.Limits.Ban.Window.Duration
+	if window <= 0 {
+		return vs
+	}
+
+	i := 0
+	for i < len(vs) && now.Sub(vs[i]) >= window {
+		i++
+	}
+	return vs[i:]
+}
+
+// ban bans ip till until and returns the ip's open connections that have to
+// be closed. The guard's lock must be held.
+func (g *ntcpGuard) ban(ip string, until time.Time) []*ntcpclient {
+	g.bans[ip] = until
+
+	var kick []*ntcpclient
+	for c := range g.conns[ip] {
+		kick = append(kick, c)
+	}
+	return kick
+}
+
+// prune removes expired violations and bans. To keep Acquire and Violation
+// cheap the maps are only iterated once per guardPruneInterval. The guard's
+// lock must be held.
+func (g *ntcpGuard) prune(now time.Time) {
+	if now.Sub(g.pruned) < guardPruneInterval {
+		return
+	}
+	g.pruned = now
+
+	for ip, vs := range g.violations {
+		if vs = g.recent(vs, now); len(vs) == 0 {
+			delete(g.violations, ip)
+		} else {
+			g.violations[ip] = vs
+		}
+	}
+	for ip, until := range g.bans {
+		if !now.Before(until) {
+			delete(g.bans, ip)
+		}
+	}
+	for userID, until := range g.userBans {
+		if !now.Before(until) {
+			delete(g.userBans, userID)
+		}
+	}
+}
+
+// Ban bans ip for the duration d and closes all of it's open connections.
+func (g *ntcpGuard) Ban(ip string, d time.Duration) {
+	g.baton.Lock()
+	kick := g.ban(ip, g.now().Add(d))
+	g.baton.Unlock()
+
+	for _, c := range kick {
+		c.Kick("ip banned")
+	}
+}
+
+// BanUser bans the user for the duration d.
+func (g *ntcpGuard) BanUser(userID int, d time.Duration) {
+	g.baton.Lock()
+	defer g.baton.Unlock()
+
+	g.userBans[userID] = g.now().Add(d)
+}
+
+// UserBanned reports whether the user is currently banned.
+func (g *ntcpGuard) UserBanned(userID int) bool {
+	g.baton.Lock()
+	defer g.baton.Unlock()
+
+	until, ok := g.userBans[userID]
+	if !ok {
+		return false
+	}
+	if g.now().Before(until) {
+		return true
+	}
+	delete(g.userBans, userID)
+	return false
+}
+
+var guard *ntcpGuard
+
+// violation records a protocol violation of the client. If the violation
+// lead to a ban the guard closed all connections of the client's ip.
+func (c *ntcpclient) violation(reason string) {
+	c.Log.Warn("protocol violation", zap.String("reason", reason))
+
+	if guard.Violation(c.PureIP) {
+		c.Log.Warn("banning ip after repeated protocol violations",
+			zap.String("pure_ip", c.PureIP),
+			zap.Duration("duration", config().Network.TCP.Limits.Ban.Duration.Duration))
+	}
+}
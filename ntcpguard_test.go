@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// withLimits runs f with the tcp limits set to the passed values.
+func withLimits(maxConns, violations int, window, ban time.Duration, f func()) {
+	prev := config()
+	defer setConfig(prev)
+
+	next := *prev
+	next.Network.TCP.Limits.MaxConnsPerIP = maxConns
+	next.Network.TCP.Limits.Ban.Violations = violations
+	next.Network.TCP.Limits.Ban.Window.Duration = window
+	next.Network.TCP.Limits.Ban.Duration.Duration = ban
+	setConfig(&next)
+	f()
+}
+
+func TestNtcpGuardAcquire(t *testing.T) {
+	assert := assert.New(t)
+
+	withLimits(2, 0, 0, 0, func() {
+		g := newNtcpGuard()
+		a1 := newTestClient("10.0.0.1")
+		a2 := newTestClient("10.0.0.1")
+		a3 := newTestClient("10.0.0.1")
+		b1 := newTestClient("10.0.0.2")
+
+		var tests = []struct {
+			Name   string
+			Do     func() error
+			Reject bool
+		}{
+			{"Test01: first connection", func() error { return g.Acquire(a1.ntcpclient) }, false},
+			{"Test02: second connection", func() error { return g.Acquire(a2.ntcpclient) }, false},
+			{"Test03: limit reached", func() error { return g.Acquire(a3.ntcpclient) }, true},
+			{"Test04: other ip", func() error { return g.Acquire(b1.ntcpclient) }, false},
+			{"Test05: released slot", func() error { g.Release(a1.ntcpclient); return g.Acquire(a3.ntcpclient) }, false},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.Name, func(t *testing.T) {
+				err := tt.Do()
+				if tt.Reject {
+					assert.Error(err)
+				} else {
+					assert.NoError(err)
+				}
+			})
+		}
+
+		g.Release(a2.ntcpclient)
+		g.Release(a3.ntcpclient)
+		g.Release(b1.ntcpclient)
+		assert.Empty(g.conns)
+	})
+}
+
+func TestNtcpGuardViolation(t *testing.T) {
+	assert := assert.New(t)
+
+	withLimits(0, 3, time.Minute, 10*time.Minute, func() {
+		prev := guard
+		defer func() { guard = prev }()
+
+		now := time.Now()
+		guard = newNtcpGuard()
+		guard.now = func() time.Time { return now }
+
+		c := newTestClient("10.0.0.1")
+		sibling := newTestClient("10.0.0.1")
+		other := newTestClient("10.0.0.2")
+		for _, tc := range []*testClient{c, sibling, other} {
+			assert.NoError(guard.Acquire(tc.ntcpclient))
+		}
+		c.Authenticated = true
+		c.IsEncrypted = true
+
+		// packets with an invalid pc are protocol violations
+		invalid := `{"type":"ping","pc":0,"obj":{}}`
+
+		var tests = []struct {
+			Name   string
+			After  time.Duration
+			Banned bool
+		}{
+			{"Test01: first violation", 0, false},
+			{"Test02: second violation", time.Second, false},
+			{"Test03: violations outside the window decay", 2 * time.Minute, false},
+			{"Test04: second violation inside the window", time.Second, false},
+			{"Test05: threshold reached", time.Second, true},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.Name, func(t *testing.T) {
+				now = now.Add(tt.After)
+				c.send(invalid)
+
+				rs := c.responses()
+				if assert.Len(rs, 1) {
+					assert.Equal("pc_mismatch", rs[0]["errorinfo"].(map[string]interface{})["name"])
+				}
+				assert.Equal(tt.Banned, c.closed())
+				assert.Equal(tt.Banned, sibling.closed())
+				assert.False(other.closed())
+			})
+		}
+
+		// the ban rejects new connections till it expires
+		assert.Error(guard.Acquire(newTestClient("10.0.0.1").ntcpclient))
+		now = now.Add(10 * time.Minute)
+		assert.NoError(guard.Acquire(newTestClient("10.0.0.1").ntcpclient))
+		assert.Empty(guard.violations)
+		assert.Empty(guard.bans)
+	})
+}
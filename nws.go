@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/vikebot/vbgs/pkg/ntfydistr"
 
@@ -20,6 +21,8 @@ var nwsUpgrader websocket.Upgrader
 
 func nwsInit(start chan bool, shutdown chan bool) {
 	nwsUpgrader = websocket.Upgrader{
+		// negotiate permessage-deflate with viewers supporting it
+		EnableCompression: true,
 		CheckOrigin: func(r *http.Request) bool {
 			origin := r.Header["Origin"]
 			if len(origin) == 0 {
@@ -29,40 +32,56 @@ func nwsInit(start chan bool, shutdown chan bool) {
 			if err != nil {
 				return false
 			}
-			return u.Host == config.Network.WS.ValidOrigin
+			return u.Host == config().Network.WS.ValidOrigin
 		},
 	}
 
-	srv := &http.Server{Addr: config.Network.WS.Addr}
+	// bind the port right away, so the listener only reports listening once
+	// it accepts viewers
+	listener, err := net.Listen("tcp", config().Network.WS.Addr)
+	if err != nil {
+		log.Fatal("nws listen failed", zap.String("addr", config().Network.WS.Addr), zap.Error(err))
+	}
+
+	srv := &http.Server{Addr: config().Network.WS.Addr}
+	services.Add(1)
+	listeners.Set("nws", listenerReady)
 	http.HandleFunc("/", nwsHandler)
+	http.HandleFunc(nsseEndpoint, nsseHandler)
 
 	go func() {
 		// Wait for start signal
 		log.Info("nws ready. waiting for start signal")
 		<-start
 
-		go nwsRun(srv)
+		go nwsRun(srv, listener)
+		listeners.Set("nws", listenerListening)
 
-		// Shutdown websocket when signal is received
+		// Shutdown websocket when signal is received. Hijacked websocket
+		// connections are released as soon as the distributor is closed
 		<-shutdown
-		err := srv.Shutdown(nil)
+		ctx, cancel := shutdownContext()
+		defer cancel()
+		err := srv.Shutdown(ctx)
 		if err != nil {
 			log.Warn("nws shutdown failed", zap.Error(err))
 		}
+		listeners.Set("nws", listenerStopped)
+		services.Done()
 	}()
 }
 
-func nwsRun(srv *http.Server) {
+func nwsRun(srv *http.Server, listener net.Listener) {
 	var srvErr error
 
 	log.Info("accepting clients on nws listener")
-	if config.Network.WS.TLS.Active {
-		srvErr = srv.ListenAndServeTLS(config.Network.WS.TLS.Cert, config.Network.WS.TLS.PKey)
+	if config().Network.WS.TLS.Active {
+		srvErr = srv.ServeTLS(listener, config().Network.WS.TLS.Cert, config().Network.WS.TLS.PKey)
 	} else {
-		srvErr = srv.ListenAndServe()
+		srvErr = srv.Serve(listener)
 	}
 
-	if srvErr != nil {
+	if srvErr != nil && srvErr != http.ErrServerClosed {
 		log.Fatal("nws listen failed", zap.Error(srvErr))
 	}
 }
@@ -71,6 +90,7 @@ func nwsHandler(w http.ResponseWriter, r *http.Request) {
 	wsrqid := strings.ToLower(vbcore.FastRandomString(16))
 	c := &nwsclient{
 		WSRqID: wsrqid,
+		Mode:   r.URL.Query().Get("mode"),
 		Log:    log.With(zap.String("wsid", wsrqid)),
 	}
 
@@ -82,6 +102,16 @@ func nwsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer func() {
+		// tell the viewer why we are closing the connection
+		if reason, ok := shuttingDown(); ok {
+			err = ws.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, reason),
+				time.Now().Add(time.Second))
+			if err != nil {
+				c.Log.Debug("sending close frame failed", zap.Error(err))
+			}
+		}
+
 		c.Log.Info("closing physical websocket connection", zap.String("ip", r.RemoteAddr))
 		err = ws.Close()
 		if err != nil {
@@ -104,47 +134,81 @@ func nwsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func nwsAuthAndValidate(c *nwsclient) error {
-	// get opening message (should be the watchtoken) from the client
-	mt, watchtoken, err := c.Ws.ReadMessage()
-	if err != nil {
-		c.Log.Warn("failed reading message from websocket", zap.Error(err))
-		return nil
-	}
-	c.Mt = mt
+// nwsRoundentryFromWatchtoken looks up the roundentry of a watchtoken. Tests
+// replace it, so viewers can be served without database.
+var nwsRoundentryFromWatchtoken = vbdb.RoundentryFromWatchtokenCtx
 
-	watchtokenStr := string(watchtoken) // TODO: check for legitimacy of watchtoken
+// nwsVerifyWatchtoken resolves the user referenced by the watchtoken. If the
+// watchtoken is invalid for this round a message for the viewer is returned.
+func nwsVerifyWatchtoken(watchtoken string, ctx *zap.Logger) (userID int, errMsg string) {
+	// TODO: check for legitimacy of watchtoken
 
 	// check if the watchtoken exists inside the database
-	v, exists, success := vbdb.RoundentryFromWatchtokenCtx(watchtokenStr, c.Log)
+	v, exists, success := nwsRoundentryFromWatchtoken(watchtoken, ctx)
 	if !success {
-		return c.WriteStr("Internal server error")
+		return 0, "Internal server error"
 	}
 	if !exists {
-		c.Log.Warn("client provided unknown watchtoken", zap.String("watchtoken", string(watchtoken)))
-		return c.WriteStr("Unknown watchtoken")
+		ctx.Warn("client provided unknown watchtoken", zap.String("watchtoken", watchtoken))
+		return 0, "Unknown watchtoken"
 	}
 
 	// user is authenticated correctly
-	c.UserID = v.UserID
-	c.Log.Info("websocket authenticated and userID resolved", zap.Int("user_id", v.UserID))
+	ctx.Info("viewer authenticated and userID resolved", zap.Int("user_id", v.UserID))
 
 	// check if the user's watchtoken was intended for this round
-	if config.Battle.RoundID != v.RoundID {
-		c.Log.Warn("valid watchtoken references invalid round",
-			zap.Int("config_round_id", config.Battle.RoundID),
+	if config().Battle.RoundID != v.RoundID {
+		ctx.Warn("valid watchtoken references invalid round",
+			zap.Int("config_round_id", config().Battle.RoundID),
 			zap.Int("watchtoken_round_id", v.RoundID))
 
-		return c.WriteStr("Unexpected watchtoken. Maybe your round is already over?")
+		return 0, "Unexpected watchtoken. Maybe your round is already over?"
 	}
 
+	return v.UserID, ""
+}
+
+func nwsAuthAndValidate(c *nwsclient) error {
+	// get opening message (should be the watchtoken) from the client
+	mt, watchtoken, err := c.Ws.ReadMessage()
+	if err != nil {
+		c.Log.Warn("failed reading message from websocket", zap.Error(err))
+		return nil
+	}
+	c.Mt = mt
+
+	userID, errMsg := nwsVerifyWatchtoken(string(watchtoken), c.Log)
+	if errMsg != "" {
+		return c.WriteStr(errMsg)
+	}
+	c.UserID = userID
+
+	nwsRegistry.Put(c)
+	defer nwsRegistry.Delete(c)
+
 	// subscribe websocket connection for all notifications to this user and
 	// send them as long as err isn't a disconnect from the remote websocket.
 	// Also send all initial informations needed by this specific subscriber,
 	// as map properties, etc.
-	dist.GetClient(strconv.Itoa(c.UserID)).Sub(ntfyWebsocketReceiver{
+	// The subscription is cancelled as soon as the viewer stops answering our
+	// pings.
+	done := c.keepalive()
+	var receiver ntfydistr.Receiver = ntfyWebsocketReceiver{
 		c: c,
-	}, c.Log)
+	}
+	if c.Mode == nwsModeDelta {
+		delta := &ntfyWebsocketDeltaReceiver{
+			ntfyWebsocketReceiver: ntfyWebsocketReceiver{
+				c: c,
+			},
+		}
+		go delta.snapshots(done)
+		receiver = delta
+	}
+	metricViewers.WithLabelValues("ws").Inc()
+	defer metricViewers.WithLabelValues("ws").Dec()
+
+	dist.GetClient(strconv.Itoa(c.UserID)).SubUntil(receiver, done, c.Log)
 	return nil
 }
 
@@ -156,20 +220,26 @@ func (r ntfyWebsocketReceiver) Init(initClient *ntfydistr.Client) {
 	// Start the initialization of the current subscriber
 	r.c.Log.Debug("initing nwsclient subscription for user")
 
+	initViewer(initClient, r.c.UserID, r.c.Log)
+}
+
+// initViewer pushes all initial game information a newly subscribed viewer of
+// the user needs into initClient.
+func initViewer(initClient *ntfydistr.Client, userID int, ctx *zap.Logger) {
 	// Construct necessary primitives
-	var player = battle.Players[r.c.UserID]
+	var player = battle.Players[userID]
 	viewableMapsize := vbge.Location{
 		X: vbge.RenderWidth,
 		Y: vbge.RenderHeight,
 	}
-	playerMapentity, err := vbge.GetViewableMapentity(viewableMapsize.X, viewableMapsize.Y, r.c.UserID, battle, true)
+	playerMapentity, err := vbge.GetViewableMapentity(viewableMapsize.X, viewableMapsize.Y, userID, battle, true)
 	if err != nil {
-		r.c.Log.Error("failed getting mapentity", zap.Error(err))
+		ctx.Error("failed getting mapentity", zap.Error(err))
 		return
 	}
 
 	// Send the initial game information
-	r.c.Log.Debug("sending init package to nwsclient")
+	ctx.Debug("sending init package to viewer")
 	initClient.Push("initial", struct {
 		TotalMapsize    vbge.Location        `json:"totalmapsize"`
 		ViewableMapsize vbge.Location        `json:"viewablemapsize"`
@@ -182,28 +252,28 @@ func (r ntfyWebsocketReceiver) Init(initClient *ntfydistr.Client) {
 			Y: vbge.MapHeight,
 		},
 		ViewableMapsize: viewableMapsize,
-		MaxHealth:       vbge.MaxHealth,
+		MaxHealth:       vbge.CurrentRules().MaxHealth,
 		Startplayer:     player.GRenderID,
 		PlayerMapentity: playerMapentity.Matrix,
-	}, r.c.Log)
+	}, ctx)
 
 	// Set the client's debug flag
-	r.c.Log.Debug("sending debug flag to nwsclient", zap.Bool("debug", config.Network.WS.Flags.Debug))
+	ctx.Debug("sending debug flag to viewer", zap.Bool("debug", config().Network.WS.Flags.Debug))
 	initClient.Push("flag", struct {
 		Name  string `json:"name"`
 		State bool   `json:"state"`
 	}{
 		"debug",
-		config.Network.WS.Flags.Debug,
-	}, r.c.Log)
+		config().Network.WS.Flags.Debug,
+	}, ctx)
 
 	// Send the current state fo the stats
-	if config.Network.WS.Flags.Stats {
-		r.c.Log.Debug("sending stats to nwsclient")
+	if config().Network.WS.Flags.Stats {
+		ctx.Debug("sending stats to viewer")
 
 		stats, err := getPlayersStats()
 		if err != nil {
-			r.c.Log.Error("failed getting stats", zap.Error(err))
+			ctx.Error("failed getting stats", zap.Error(err))
 			return
 		}
 
@@ -211,7 +281,7 @@ func (r ntfyWebsocketReceiver) Init(initClient *ntfydistr.Client) {
 			Stats playersStats `json:"stats"`
 		}{
 			stats,
-		}, r.c.Log)
+		}, ctx)
 	}
 }
 
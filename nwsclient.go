@@ -2,6 +2,7 @@ package main
 
 import (
 	"sync"
+	"time"
 
 	"github.com/eapache/queue"
 	"github.com/gorilla/websocket"
@@ -11,6 +12,7 @@ import (
 type nwsclient struct {
 	WSRqID   string
 	UserID   int
+	Mode     string
 	Mt       int
 	Ws       *websocket.Conn
 	Queue    *queue.Queue
@@ -19,9 +21,79 @@ type nwsclient struct {
 }
 
 func (c *nwsclient) Write(buf []byte) error {
+	if config().Network.WS.Timeouts.Write.Duration > 0 {
+		err := c.Ws.SetWriteDeadline(time.Now().Add(config().Network.WS.Timeouts.Write.Duration))
+		if err != nil {
+			return err
+		}
+	}
 	return c.Ws.WriteMessage(c.Mt, buf)
 }
 
+// keepalive starts reading from the websocket (which is needed to process
+// pong control frames) and periodically pings the remote party. The returned
+// channel is closed as soon as the remote party stops responding or the
+// connection is closed.
+func (c *nwsclient) keepalive() <-chan struct{} {
+	dead := make(chan struct{})
+
+	pong := config().Network.WS.Timeouts.Pong.Duration
+	ping := config().Network.WS.Timeouts.Ping.Duration
+
+	extendDeadline := func(string) error {
+		if pong <= 0 {
+			return nil
+		}
+		return c.Ws.SetReadDeadline(time.Now().Add(pong))
+	}
+
+	// read pump: viewers don't send any messages after authenticating, so
+	// everything read is discarded. The pump only exits on errors (e.g. timed
+	// out pongs or closed connections)
+	go func() {
+		defer close(dead)
+
+		err := extendDeadline("")
+		if err != nil {
+			c.Log.Warn("setting websocket read deadline failed", zap.Error(err))
+			return
+		}
+		c.Ws.SetPongHandler(extendDeadline)
+
+		for {
+			_, _, err := c.Ws.ReadMessage()
+			if err != nil {
+				c.Log.Info("websocket peer stopped responding", zap.Error(err))
+				return
+			}
+		}
+	}()
+
+	if ping <= 0 {
+		return dead
+	}
+
+	// ping pump
+	go func() {
+		tick := time.NewTicker(ping)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-dead:
+				return
+			case <-tick.C:
+				err := c.Ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(ping))
+				if err != nil {
+					c.Log.Debug("sending websocket ping failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	return dead
+}
+
 func (c *nwsclient) WriteStr(str string) error {
 	return c.Write([]byte(str))
 }
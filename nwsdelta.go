@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/vikebot/vbgs/pkg/ntfydistr"
+	"github.com/vikebot/vbgs/vbge"
+	"go.uber.org/zap"
+)
+
+// nwsModeDelta is the viewer protocol mode (selected with the `mode` query
+// parameter) that replaces full map lines with compact deltas and periodic
+// snapshots. A delta's shift tells the viewer how many columns (x) and rows
+// (y) it's map moved, before the changes are applied.
+const nwsModeDelta = "delta"
+
+// defaultSnapshotInterval is used if no interval is configured
+const defaultSnapshotInterval = 30 * time.Second
+
+type deltaEvent struct {
+	Type  string      `json:"type"`
+	Obj   interface{} `json:"obj"`
+	Unixn int64       `json:"unixn"`
+}
+
+// ntfyWebsocketDeltaReceiver is like ntfyWebsocketReceiver, but instead of
+// sending the full map lines included in move notifications it remembers the
+// last state of the viewer's map and only sends changed locations. In regular
+// intervals a full snapshot is sent to resynchronize the viewer.
+type ntfyWebsocketDeltaReceiver struct {
+	ntfyWebsocketReceiver
+
+	// state is the map the viewer currently knows. stateSync also serializes
+	// all writes to the websocket
+	state     *vbge.ViewableMapentity
+	stateSync sync.Mutex
+}
+
+// deltaShift tells the viewer how far it's map has to be shifted before the
+// changes of a delta are applied.
+type deltaShift struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type deltaObj struct {
+	Shift   deltaShift         `json:"shift"`
+	Changes []vbge.EntityDelta `json:"changes"`
+}
+
+type snapshotObj struct {
+	PlayerMapentity [][]*vbge.EntityResp `json:"playermapentity"`
+}
+
+func (r *ntfyWebsocketDeltaReceiver) Init(initClient *ntfydistr.Client) {
+	r.ntfyWebsocketReceiver.Init(initClient)
+
+	r.stateSync.Lock()
+	defer r.stateSync.Unlock()
+
+	// the initial packet contains the full map -> it's our first snapshot
+	r.state, _ = r.viewableMapentity()
+}
+
+func (r *ntfyWebsocketDeltaReceiver) viewableMapentity() (*vbge.ViewableMapentity, error) {
+	return vbge.GetViewableMapentity(vbge.RenderWidth, vbge.RenderHeight, r.c.UserID, battle, true)
+}
+
+func (r *ntfyWebsocketDeltaReceiver) Write(notf []byte) (disconnected bool, err error) {
+	r.stateSync.Lock()
+	defer r.stateSync.Unlock()
+
+	buf, err := r.compact(notf)
+	if err != nil {
+		r.c.Log.Warn("unable to compact notifications. sending them unchanged", zap.Error(err))
+		buf = notf
+	}
+
+	return r.ntfyWebsocketReceiver.Write(buf)
+}
+
+// snapshots sends a full snapshot of the viewer's map every snapshot interval
+// until done is closed.
+func (r *ntfyWebsocketDeltaReceiver) snapshots(done <-chan struct{}) {
+	interval := config().Network.WS.SnapshotInterval.Duration
+	if interval <= 0 {
+		interval = defaultSnapshotInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			err := r.snapshot()
+			if err != nil {
+				r.c.Log.Warn("sending snapshot failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// snapshot sends the viewer's complete map and resets the remembered state.
+func (r *ntfyWebsocketDeltaReceiver) snapshot() error {
+	r.stateSync.Lock()
+	defer r.stateSync.Unlock()
+
+	// the viewer hasn't received it's initial map yet
+	if r.state == nil {
+		return nil
+	}
+
+	next, err := r.viewableMapentity()
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal([]deltaEvent{{"snapshot", snapshotObj{next.Matrix}, time.Now().UTC().UnixNano()}})
+	if err != nil {
+		return err
+	}
+
+	r.state = next
+	_, err = r.ntfyWebsocketReceiver.Write(buf)
+	return err
+}
+
+// compact removes the map lines from all move notifications and appends a
+// delta notification describing the changes of the viewer's map. The caller
+// must hold stateSync.
+func (r *ntfyWebsocketDeltaReceiver) compact(notf []byte) ([]byte, error) {
+	var events []struct {
+		Type  string          `json:"type"`
+		Obj   json.RawMessage `json:"obj"`
+		Unixn int64           `json:"unixn"`
+	}
+	err := json.Unmarshal(notf, &events)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]deltaEvent, 0, len(events)+1)
+	changed := false
+	for _, e := range events {
+		if e.Type != "game" {
+			out = append(out, deltaEvent{e.Type, e.Obj, e.Unixn})
+			continue
+		}
+		changed = true
+
+		var obj map[string]json.RawMessage
+		err = json.Unmarshal(e.Obj, &obj)
+		if err != nil {
+			return nil, err
+		}
+		delete(obj, "newline")
+		out = append(out, deltaEvent{e.Type, obj, e.Unixn})
+	}
+
+	// nothing happened around the viewer -> the map is unchanged
+	if !changed {
+		return notf, nil
+	}
+
+	next, err := r.viewableMapentity()
+	if err != nil {
+		return nil, err
+	}
+
+	dx, dy := r.state.Shift(next)
+	if deltas := r.state.Diff(next); len(deltas) > 0 || dx != 0 || dy != 0 {
+		out = append(out, deltaEvent{"delta", deltaObj{
+			Shift:   deltaShift{dx, dy},
+			Changes: deltas,
+		}, time.Now().UTC().UnixNano()})
+	}
+	r.state = next
+
+	return json.Marshal(out)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vikebot/vbgs/vbge"
+)
+
+func TestNwsDeltaMove(t *testing.T) {
+	assert := assert.New(t)
+
+	prev := battle
+	defer func() { battle = prev }()
+
+	m := vbge.NewMapEntity(vbge.MapWidth, vbge.MapHeight)
+	p := &vbge.Player{
+		UserID:    1,
+		Map:       m,
+		GRenderID: "1",
+		WatchDir:  "north",
+		Health:    vbge.NewDefaultHealth(),
+		Rl:        vbge.NewOpLimitations(),
+		Location:  &vbge.Location{X: vbge.HalfmapWidth, Y: vbge.HalfmapHeight},
+	}
+	m.Matrix[p.Location.Y][p.Location.X].JoinArea(p)
+	battle = &vbge.Battle{
+		Map:     m,
+		Players: map[int]*vbge.Player{1: p},
+	}
+
+	var tests = []struct {
+		Name  string
+		Dir   string
+		Shift deltaShift
+	}{
+		{"Test01: north", "north", deltaShift{0, -1}},
+		{"Test02: east", "east", deltaShift{1, 0}},
+		{"Test03: south", "south", deltaShift{0, 1}},
+		{"Test04: west", "west", deltaShift{-1, 0}},
+	}
+
+	r := &ntfyWebsocketDeltaReceiver{
+		ntfyWebsocketReceiver: ntfyWebsocketReceiver{
+			c: &nwsclient{UserID: 1},
+		},
+	}
+	r.state, _ = r.viewableMapentity()
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			_, err := p.Move(tt.Dir)
+			assert.NoError(err)
+
+			buf, err := r.compact([]byte(`[{"type":"game","obj":{"type":"move","newline":[[]]},"unixn":1}]`))
+			assert.NoError(err)
+
+			var events []struct {
+				Type string          `json:"type"`
+				Obj  json.RawMessage `json:"obj"`
+			}
+			assert.NoError(json.Unmarshal(buf, &events))
+			if !assert.Len(events, 2) {
+				return
+			}
+			assert.NotContains(string(events[0].Obj), "newline")
+			assert.Equal("delta", events[1].Type)
+
+			var delta deltaObj
+			assert.NoError(json.Unmarshal(events[1].Obj, &delta))
+			assert.Equal(tt.Shift, delta.Shift)
+
+			// the newly visible line plus the player's old and new location
+			assert.Len(delta.Changes, vbge.RenderWidth+2)
+		})
+	}
+}
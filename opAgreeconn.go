@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+type agreeconnObj struct {
+}
+
+type agreeconnPacket struct {
+	Type string       `json:"type"`
+	Obj  agreeconnObj `json:"obj"`
+}
+
+// agreeconnResponse references the session the client can resume on another
+// connection. It's only sent encrypted, as the token must never be visible in
+// plain text.
+type agreeconnResponse struct {
+	Session     string `json:"session"`
+	ResumeToken string `json:"resumetoken"`
+}
+
+func init() {
+	ops.Register(&op{
+		Name:      "agreeconn",
+		NewPacket: func() interface{} { return &agreeconnPacket{} },
+		Handler:   func(c *ntcpclient, p interface{}) { opAgreeconn(c, *p.(*agreeconnPacket)) },
+		Handshake: true,
+	})
+}
+
+func opAgreeconn(c *ntcpclient, packet agreeconnPacket) {
+	// Check if this client has already a agreed connection
+	if err := ntcpRegistry.Put(c); err != nil {
+		log.Warn("multiple connections for same user", zap.Error(err))
+		c.RespondErr(errConnectionOpen.New("Connection already open - Please close any previous connections or resume your session before initializing a new one."))
+		return
+	}
+
+	// Open the session that allows the client to take it over from another
+	// connection if this one drops
+	sess, token, err := sessionRegistry.Open(c)
+	if err != nil {
+		c.Log.Error("failed to open resumable session", zap.Error(err))
+		ntcpRegistry.Delete(c)
+		c.RespondErr(errInternal.New(statusInternalServerError))
+		return
+	}
+
+	c.AgreeconnDone = true
+	c.Authenticated = true
+	c.Player = battle.Players[c.UserID]
+
+	c.RespondObj(&agreeconnResponse{
+		Session:     sess.ID,
+		ResumeToken: token,
+	})
+	dist.GetClient(strconv.Itoa(c.UserID)).PushInfo(true, c.IP, c.SDK, c.SDKLink, c.OS, c.Log)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// maxBatchOps is the maximum amount of ops a single batch packet can contain
+const maxBatchOps = 16
+
+type batchObj struct {
+	Ops          *[]json.RawMessage `json:"ops"`
+	AbortOnError bool               `json:"abortonerror"`
+}
+
+type batchPacket struct {
+	Type string   `json:"type"`
+	Obj  batchObj `json:"obj"`
+}
+
+type batchResult struct {
+	Type      string         `json:"type"`
+	Error     *string        `json:"error"`
+	ErrorInfo *responseError `json:"errorinfo,omitempty"`
+	Obj       interface{}    `json:"obj,omitempty"`
+}
+
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+func init() {
+	ops.Register(&op{
+		Name:      "batch",
+		NewPacket: func() interface{} { return &batchPacket{} },
+		Validate: func(p interface{}) *opError {
+			batchOps := p.(*batchPacket).Obj.Ops
+			if batchOps == nil {
+				return errInvalidPacket.New("Invalid packet. '.obj.ops' missing").With("field", ".obj.ops")
+			}
+			if len(*batchOps) == 0 {
+				return errInvalidPacket.New("Invalid packet. '.obj.ops' must contain at least one op").With("field", ".obj.ops")
+			}
+			if len(*batchOps) > maxBatchOps {
+				return errInvalidPacket.Newf("Invalid packet. '.obj.ops' can contain a maximum of %d ops", maxBatchOps).
+					With("field", ".obj.ops").
+					With("max", maxBatchOps)
+			}
+			return nil
+		},
+		Handler: func(c *ntcpclient, p interface{}) { opBatch(c, *p.(*batchPacket)) },
+		Passive: true,
+	})
+}
+
+func opBatch(c *ntcpclient, packet batchPacket) {
+	batchOps := *packet.Obj.Ops
+
+	// Collect the responses of all ops instead of sending them. Every op gets
+	// it's own result at the op's index, even if it doesn't respond
+	results := make([]batchResult, len(batchOps))
+
+	for i, data := range batchOps {
+		c.CurType = "forbidden"
+		results[i].Type = c.CurType
+		c.batch = &results[i]
+
+		var sub typePacket
+		err := json.Unmarshal(data, &sub)
+		if err != nil {
+			c.RespondErr(errInvalidJSON.New(statusInvalidJSON))
+		} else if sub.Type == nil {
+			c.RespondErr(errInvalidPacket.Newf("Invalid packet. '.obj.ops[%d].type' missing", i).
+				With("field", fmt.Sprintf(".obj.ops[%d].type", i)))
+		} else if o := ops.Get(*sub.Type); o != nil && (o.Handshake || o.Name == "batch") {
+			c.CurType = *sub.Type
+			c.RespondErr(errNotInBatch.Newf("Invalid packet. '%s' isn't allowed inside a batch", *sub.Type))
+		} else {
+			// Execute the op like any other packet. Every op takes it's own
+			// cooldown
+			c.CurType = *sub.Type
+			results[i].Type = c.CurType
+			dispatch(c, data, sub)
+		}
+
+		// Skipped ops don't get a result
+		if packet.Obj.AbortOnError && results[i].Error != nil {
+			results = results[:i+1]
+			break
+		}
+	}
+
+	// Send all collected results as single response
+	c.batch = nil
+	c.CurType = "batch"
+	c.RespondObj(&batchResponse{
+		Results: results,
+	})
+}
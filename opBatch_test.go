@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	// testsilent is an op that never responds
+	ops.Register(&op{
+		Name:      "testsilent",
+		NewPacket: func() interface{} { return &pingPacket{} },
+		Handler:   func(c *ntcpclient, p interface{}) {},
+		Passive:   true,
+	})
+}
+
+func TestOpBatch(t *testing.T) {
+	assert := assert.New(t)
+
+	// result describes a single batch result through it's type and error name
+	type result struct {
+		Type  string
+		Error string
+	}
+
+	var tests = []struct {
+		Name    string
+		Packet  string
+		Results []result
+	}{
+		{"Test01: results match the submitted ops",
+			`{"type":"batch","obj":{"ops":[{"type":"ping"},{"type":"testsilent"},{"type":"ping"}]}}`,
+			[]result{{"ping", ""}, {"testsilent", ""}, {"ping", ""}}},
+		{"Test02: errors keep their position",
+			`{"type":"batch","obj":{"ops":[{"type":"login"},{"obj":{}},{"type":"unknownop"},{"type":"ping"}]}}`,
+			[]result{{"login", "not_allowed_in_batch"}, {"forbidden", "invalid_packet"}, {"forbidden", "unknown_type"}, {"ping", ""}}},
+		{"Test03: abort skips the remaining ops",
+			`{"type":"batch","obj":{"abortonerror":true,"ops":[{"type":"testsilent"},{"type":"batch"},{"type":"ping"}]}}`,
+			[]result{{"testsilent", ""}, {"batch", "not_allowed_in_batch"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			c := newTestClient("10.0.0.1")
+			c.Authenticated = true
+
+			c.send(tt.Packet)
+
+			rs := c.responses()
+			if !assert.Len(rs, 1) {
+				return
+			}
+			assert.Equal("batch", rs[0]["type"])
+
+			var got []result
+			for _, r := range rs[0]["obj"].(map[string]interface{})["results"].([]interface{}) {
+				r := r.(map[string]interface{})
+				res := result{Type: r["type"].(string)}
+				if info, ok := r["errorinfo"].(map[string]interface{}); ok {
+					res.Error = info["name"].(string)
+				}
+				got = append(got, res)
+			}
+			assert.Equal(tt.Results, got)
+		})
+	}
+}
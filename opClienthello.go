@@ -16,26 +16,37 @@ type xhelloPacket struct {
 	Obj  xhelloObj `json:"obj"`
 }
 
-func opClienthello(c *ntcpclient, packet xhelloPacket) {
-	if packet.Obj.Cipher == nil {
-		c.Respond("Invalid packet. '.obj.cipher' missing")
-		return
-	}
+func init() {
+	ops.Register(&op{
+		Name:      "clienthello",
+		NewPacket: func() interface{} { return &xhelloPacket{} },
+		Validate: func(p interface{}) *opError {
+			if p.(*xhelloPacket).Obj.Cipher == nil {
+				return errInvalidPacket.New("Invalid packet. '.obj.cipher' missing").With("field", ".obj.cipher")
+			}
+			return nil
+		},
+		Handler:   func(c *ntcpclient, p interface{}) { opClienthello(c, *p.(*xhelloPacket)) },
+		Handshake: true,
+	})
+}
 
+func opClienthello(c *ntcpclient, packet xhelloPacket) {
 	var plain string
 	if envDisableCrypt {
 		plain = *packet.Obj.Cipher
 	} else {
 		buf, err := base64.RawStdEncoding.DecodeString(*packet.Obj.Cipher)
 		if err != nil {
-			c.Respond("Invalid packet. '.obj.cipher' must be a base64 string")
+			c.RespondErr(errInvalidPacket.New("Invalid packet. '.obj.cipher' must be a base64 string").With("field", ".obj.cipher"))
 			return
 		}
 
 		plainBuf, err := c.Crypt.Decrypt(buf)
 		if err != nil {
 			c.Log.Warn("failed to decrypt", zap.Error(err))
-			c.Respond("Invalid cipher text - unable to decrypt")
+			c.RespondErr(errInvalidCipher.New("Invalid cipher text - unable to decrypt"))
+			c.violation("undecryptable cipher")
 			return
 		}
 
@@ -43,16 +54,16 @@ func opClienthello(c *ntcpclient, packet xhelloPacket) {
 	}
 
 	if !strings.HasPrefix(plain, "clienthello:") || strings.Count(plain, ":") != 1 {
-		c.Respond("Invalid plain text - expecting 'clienthello:YOURCHALLENGE'")
+		c.RespondErr(errInvalidHello.New("Invalid plain text - expecting 'clienthello:YOURCHALLENGE'"))
 		return
 	} else if plain == "clienthello:YOURCHALLENGE" {
-		c.Respond("Invalid server challenge in '.obj.cipher' - 'YOURCHALLENGE' is not allowed")
+		c.RespondErr(errInvalidHello.New("Invalid server challenge in '.obj.cipher' - 'YOURCHALLENGE' is not allowed"))
 		return
 	}
 
 	challenge := strings.Split(plain, ":")[1]
 	if len(challenge) > 32 {
-		c.Respond("Invalid server challenge in '.obj.cipher' - Maximum of 32 characters")
+		c.RespondErr(errInvalidHello.New("Invalid server challenge in '.obj.cipher' - Maximum of 32 characters").With("max", 32))
 		return
 	}
 
@@ -62,7 +73,7 @@ func opClienthello(c *ntcpclient, packet xhelloPacket) {
 		cipherBuf, err := c.Crypt.Encrypt([]byte(cipher))
 		if err != nil {
 			c.Log.Error("failed to encrypt serverhello challenge response", zap.Error(err))
-			c.Respond(statusInternalServerError)
+			c.RespondErr(errInternal.New(statusInternalServerError))
 			return
 		}
 
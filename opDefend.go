@@ -1,7 +1,5 @@
 package main
 
-import "time"
-
 type defendObj struct {
 }
 
@@ -10,13 +8,19 @@ type defendPacket struct {
 	Obj  defendObj `json:"obj"`
 }
 
-func opDefend(c *ntcpclient, packet defendPacket) {
-	// c.Player.Rl.Defend.Take()
-	time.Sleep(1000 * time.Millisecond)
+func init() {
+	ops.Register(&op{
+		Name:      "defend",
+		NewPacket: func() interface{} { return &defendPacket{} },
+		Cooldown:  func(c *ntcpclient) { c.Player.Rl.Defend.Take() },
+		Handler:   func(c *ntcpclient, p interface{}) { opDefend(c, *p.(*defendPacket)) },
+	})
+}
 
+func opDefend(c *ntcpclient, packet defendPacket) {
 	ng, err := c.Player.Defend()
 	if err != nil {
-		c.Respond(err.Error())
+		c.RespondErr(gameError(err))
 		return
 	}
 	c.RespondNil()
@@ -12,9 +12,17 @@ type environmentResponse struct {
 	EnvironmentMatrix [][]string `json:"environment_matrix"`
 }
 
-func opEnvironment(c *ntcpclient, packet environmentPacket) {
-	c.Player.Rl.Environment.Take()
+func init() {
+	ops.Register(&op{
+		Name:      "environment",
+		NewPacket: func() interface{} { return &environmentPacket{} },
+		Cooldown:  func(c *ntcpclient) { c.Player.Rl.Environment.Take() },
+		Handler:   func(c *ntcpclient, p interface{}) { opEnvironment(c, *p.(*environmentPacket)) },
+		Passive:   true,
+	})
+}
 
+func opEnvironment(c *ntcpclient, packet environmentPacket) {
 	matrix, ngl := c.Player.Environment()
 
 	c.RespondObj(&environmentResponse{
@@ -12,9 +12,17 @@ type healthResponse struct {
 	Health int `json:"value"`
 }
 
-func opHealth(c *ntcpclient, packtet healthPacket) {
-	c.Player.Rl.Health.Take()
+func init() {
+	ops.Register(&op{
+		Name:      "health",
+		NewPacket: func() interface{} { return &healthPacket{} },
+		Cooldown:  func(c *ntcpclient) { c.Player.Rl.Health.Take() },
+		Handler:   func(c *ntcpclient, p interface{}) { opHealth(c, *p.(*healthPacket)) },
+		Passive:   true,
+	})
+}
 
+func opHealth(c *ntcpclient, packtet healthPacket) {
 	c.RespondObj(&healthResponse{
 		Health: c.Player.Health.HealthSynced(),
 	})
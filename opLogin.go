@@ -15,42 +15,60 @@ type loginPacket struct {
 	Obj  loginObj `json:"obj"`
 }
 
-func opLogin(c *ntcpclient, packet loginPacket) {
-	if packet.Obj.RoundTicket == nil {
-		c.Respond("Invalid packet. '.obj.roundticket' missing")
-		return
-	}
+func init() {
+	ops.Register(&op{
+		Name:      "login",
+		NewPacket: func() interface{} { return &loginPacket{} },
+		Validate: func(p interface{}) *opError {
+			if p.(*loginPacket).Obj.RoundTicket == nil {
+				return errInvalidPacket.New("Invalid packet. '.obj.roundticket' missing").With("field", ".obj.roundticket")
+			}
+			return nil
+		},
+		Handler:   func(c *ntcpclient, p interface{}) { opLogin(c, *p.(*loginPacket)) },
+		Handshake: true,
+	})
+}
 
+func opLogin(c *ntcpclient, packet loginPacket) {
 	v, exists, success := vbdb.RoundentryFromRoundticketCtx(*packet.Obj.RoundTicket, c.Log)
 	if !success {
-		c.Respond(statusInternalServerError)
+		c.RespondErr(errInternal.New(statusInternalServerError))
 		return
 	}
 	if !exists {
-		c.Respond("Your rounticket doesn't reference any game.")
+		c.RespondErr(errUnknownTicket.New("Your rounticket doesn't reference any game."))
 		return
 	}
 
-	if config.Battle.RoundID != v.RoundID {
-		c.Respond("Your roundticket references an already finished game.")
+	if config().Battle.RoundID != v.RoundID {
+		c.RespondErr(errRoundFinished.New("Your roundticket references an already finished game."))
 		c.Log.Warn("valid watchtoken references invalid round",
-			zap.Int("config_round_id", config.Battle.RoundID),
+			zap.Int("config_round_id", config().Battle.RoundID),
 			zap.Int("watchtoken_round_id", v.RoundID))
 		return
 	}
 
+	if guard.UserBanned(v.UserID) {
+		c.RespondErr(errBanned.New("You are banned from this game."))
+		return
+	}
+
 	c.UserID = v.UserID
 
+	// a fresh login supersedes all sessions the user could resume
+	sessionRegistry.DeleteUser(c.UserID)
+
 	keybuf, err := base64.StdEncoding.DecodeString(*v.AESKey)
 	if err != nil {
 		c.Log.Error("failed to decode base64 string", zap.String("aeskey", *v.AESKey))
-		c.Respond(statusInternalServerError)
+		c.RespondErr(errInternal.New(statusInternalServerError))
 		return
 	}
 	err = c.InitAes(keybuf)
 	if err != nil {
 		c.Log.Error("failed to init AES from key buffer", zap.Error(err))
-		c.Respond(statusInternalServerError)
+		c.RespondErr(errInternal.New(statusInternalServerError))
 		return
 	}
 
@@ -2,7 +2,6 @@ package main
 
 import (
 	"strconv"
-	"time"
 
 	"github.com/vikebot/vbgs/vbge"
 )
@@ -15,23 +14,33 @@ type movePacket struct {
 	Obj  moveObj `json:"obj"`
 }
 
-func opMove(c *ntcpclient, packet movePacket) {
-	//c.Player.Rl.Move.Take()
-	time.Sleep(1000 * time.Millisecond)
-	if packet.Obj.Direction == nil {
-		c.Respond("Invalid packet. '.obj.direction' missing")
-		return
-	}
+func init() {
+	ops.Register(&op{
+		Name:      "move",
+		NewPacket: func() interface{} { return &movePacket{} },
+		Validate: func(p interface{}) *opError {
+			dir := p.(*movePacket).Obj.Direction
+			if dir == nil {
+				return errInvalidPacket.New("Invalid packet. '.obj.direction' missing").With("field", ".obj.direction")
+			}
+			if !vbge.IsDir(*dir) {
+				return errInvalidPacket.Newf("Invalid packet. '%s' is not a valid value for '.obj.direction'", *dir).
+					With("field", ".obj.direction").
+					With("value", *dir)
+			}
+			return nil
+		},
+		Cooldown: func(c *ntcpclient) { c.Player.Rl.Move.Take() },
+		Handler:  func(c *ntcpclient, p interface{}) { opMove(c, *p.(*movePacket)) },
+	})
+}
 
+func opMove(c *ntcpclient, packet movePacket) {
 	dir := *packet.Obj.Direction
-	if !vbge.IsDir(dir) {
-		c.RespondFmt("Invalid packet. '%s' is not a valid value for '.obj.direction'", *packet.Obj.Direction)
-		return
-	}
 
 	ngl, err := c.Player.Move(dir)
 	if err != nil {
-		c.Respond(err.Error())
+		c.RespondErr(gameError(err))
 		return
 	}
 
@@ -0,0 +1,32 @@
+package main
+
+import "time"
+
+type pingObj struct {
+}
+
+type pingPacket struct {
+	Type string  `json:"type"`
+	Obj  pingObj `json:"obj"`
+}
+
+type pingResponse struct {
+	Unixn int64 `json:"unixn"`
+}
+
+func init() {
+	ops.Register(&op{
+		Name:      "ping",
+		NewPacket: func() interface{} { return &pingPacket{} },
+		Handler:   func(c *ntcpclient, p interface{}) { opPing(c, *p.(*pingPacket)) },
+		Passive:   true,
+	})
+}
+
+// opPing answers with the current server time. It has no effect on the game
+// and is intended as heartbeat to keep idle connections open.
+func opPing(c *ntcpclient, packet pingPacket) {
+	c.RespondObj(&pingResponse{
+		Unixn: time.Now().UTC().UnixNano(),
+	})
+}
@@ -12,9 +12,17 @@ type radarResponse struct {
 	Counter int `json:"counter"`
 }
 
-func opRadar(c *ntcpclient, packet radarPacket) {
-	c.Player.Rl.Radar.Take()
+func init() {
+	ops.Register(&op{
+		Name:      "radar",
+		NewPacket: func() interface{} { return &radarPacket{} },
+		Cooldown:  func(c *ntcpclient) { c.Player.Rl.Radar.Take() },
+		Handler:   func(c *ntcpclient, p interface{}) { opRadar(c, *p.(*radarPacket)) },
+		Passive:   true,
+	})
+}
 
+func opRadar(c *ntcpclient, packet radarPacket) {
 	counter, _ := c.Player.Radar()
 
 	c.RespondObj(&radarResponse{
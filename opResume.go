@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type resumeObj struct {
+	Session *string `json:"session"`
+	Cipher  *string `json:"cipher"`
+}
+type resumePacket struct {
+	Type string    `json:"type"`
+	Obj  resumeObj `json:"obj"`
+}
+
+func init() {
+	ops.Register(&op{
+		Name:      "resume",
+		NewPacket: func() interface{} { return &resumePacket{} },
+		Validate: func(p interface{}) *opError {
+			if p.(*resumePacket).Obj.Session == nil {
+				return errInvalidPacket.New("Invalid packet. '.obj.session' missing").With("field", ".obj.session")
+			}
+			if p.(*resumePacket).Obj.Cipher == nil {
+				return errInvalidPacket.New("Invalid packet. '.obj.cipher' missing").With("field", ".obj.cipher")
+			}
+			return nil
+		},
+		Handler:   func(c *ntcpclient, p interface{}) { opResume(c, *p.(*resumePacket)) },
+		Handshake: true,
+	})
+}
+
+// resumeToken decrypts the cipher of a resume packet with the session's key.
+// The plain text has the format 'resume:TOKEN'.
+func resumeToken(s *session, cipher string) (string, *opError) {
+	plain := cipher
+	if !envDisableCrypt {
+		buf, err := base64.RawStdEncoding.DecodeString(cipher)
+		if err != nil {
+			return "", errInvalidPacket.New("Invalid packet. '.obj.cipher' must be a base64 string").With("field", ".obj.cipher")
+		}
+
+		plainBuf, err := s.Crypt.Decrypt(buf)
+		if err != nil {
+			return "", errInvalidCipher.New("Invalid cipher text - unable to decrypt")
+		}
+		plain = string(plainBuf)
+	}
+
+	if !strings.HasPrefix(plain, "resume:") {
+		return "", errInvalidCipher.New("Invalid plain text - expecting 'resume:YOURRESUMETOKEN'")
+	}
+	return strings.TrimPrefix(plain, "resume:"), nil
+}
+
+func opResume(c *ntcpclient, packet resumePacket) {
+	sess := sessionRegistry.Get(*packet.Obj.Session)
+	if sess == nil {
+		c.RespondErr(errUnknownSession.New("Your session doesn't reference any resumable session."))
+		c.violation("unknown resume session")
+		return
+	}
+
+	token, opErr := resumeToken(sess, *packet.Obj.Cipher)
+	if opErr != nil {
+		c.RespondErr(opErr)
+		c.violation("invalid resume cipher")
+		return
+	}
+
+	// Tokens are only valid once. If another connection resumed the session
+	// in the meantime the token is already invalidated
+	if !sessionRegistry.Take(sess, token) {
+		c.RespondErr(errUnknownSession.New("Your resumetoken doesn't reference any session."))
+		c.violation("unknown resume token")
+		return
+	}
+
+	if guard.UserBanned(sess.UserID) {
+		c.RespondErr(errBanned.New("You are banned from this game."))
+		return
+	}
+
+	// Close the previous connection if it is still alive (e.g. half-dead
+	// connections that never closed) and wait till it stopped. Only
+	// afterwards it's state can be taken over safely
+	prev := sess.Client
+	prev.Kick("session resumed on another connection")
+	prev.wait()
+
+	// Take over the complete session state. The pc continues where the
+	// previous connection stopped
+	c.UserID = sess.UserID
+	c.Crypt = sess.Crypt
+	c.SDK = prev.SDK
+	c.SDKLink = prev.SDKLink
+	c.OS = prev.OS
+	c.Player = prev.Player
+	c.StartPc = prev.StartPc
+	c.Pc = prev.Pc
+
+	c.LoginDone = true
+	c.ClienthelloDone = true
+	c.AgreeconnDone = true
+	c.Authenticated = true
+
+	// Another connection of the user could have registered after the
+	// previous one stopped. It's now stale
+	if stale := ntcpRegistry.Replace(c); stale != nil {
+		stale.Kick("session resumed on another connection")
+	}
+
+	c.Log = c.Log.With(zap.Int("user_id", c.UserID))
+
+	// Rotate the token, so the used one can't be replayed
+	next, nextToken, err := sessionRegistry.Open(c)
+	if err != nil {
+		c.Log.Error("failed to open resumable session", zap.Error(err))
+		ntcpRegistry.Delete(c)
+		c.RespondErr(errInternal.New(statusInternalServerError))
+		return
+	}
+	c.Log.Info("resumed session")
+
+	c.IsEncrypted = true
+	c.RespondObj(&agreeconnResponse{
+		Session:     next.ID,
+		ResumeToken: nextToken,
+	})
+
+	// continue pushing the events the previous connection subscribed to
+	if events := prev.subscribedEvents(); events != nil {
+		c.subscribeEvents(events)
+	}
+	dist.GetClient(strconv.Itoa(c.UserID)).PushInfo(true, c.IP, c.SDK, c.SDKLink, c.OS, c.Log)
+}
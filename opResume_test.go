@@ -0,0 +1,136 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// agree finishes the handshake of c for the user and returns the session
+// and resume token from the agreeconn response.
+func agree(assert *assert.Assertions, c *testClient, userID int) (session, token string) {
+	c.UserID = userID
+	c.LoginDone = true
+	c.ClienthelloDone = true
+	c.send(`{"type":"agreeconn","obj":{}}`)
+
+	rs := c.responses()
+	if !assert.Len(rs, 1) || !assert.Nil(rs[0]["error"]) {
+		return "", ""
+	}
+	obj := rs[0]["obj"].(map[string]interface{})
+	return obj["session"].(string), obj["resumetoken"].(string)
+}
+
+// resume sends a resume packet for the session and returns the error name
+// of the response or an empty string on success.
+func resume(assert *assert.Assertions, c *testClient, session, token string) string {
+	c.send(`{"type":"resume","obj":{"session":"` + session + `","cipher":"resume:` + token + `"}}`)
+
+	rs := c.responses()
+	if !assert.Len(rs, 1) {
+		return ""
+	}
+	if info, ok := rs[0]["errorinfo"].(map[string]interface{}); ok {
+		return info["name"].(string)
+	}
+	return ""
+}
+
+func TestOpResume(t *testing.T) {
+	assert := assert.New(t)
+
+	prev := newTestClient("10.0.1.1")
+	session, token := agree(assert, prev, 1)
+	prev.Pc = 41
+	prev.serve()
+
+	c := newTestClient("10.0.1.2")
+	if !assert.Equal("", resume(assert, c, session, token)) {
+		return
+	}
+
+	// the new connection took over the session and the previous one is gone
+	assert.True(prev.closed())
+	assert.Equal(1, c.UserID)
+	assert.True(c.Authenticated)
+	assert.True(c.AgreeconnDone)
+	assert.Equal(uint32(42), c.Pc)
+	assert.Equal(c.ntcpclient, ntcpRegistry.Get(1))
+
+	// the token was rotated, so the used one can't be replayed
+	replay := newTestClient("10.0.1.3")
+	assert.Equal("unknown_resumetoken", resume(assert, replay, session, token))
+	assert.False(replay.Authenticated)
+
+	ntcpRegistry.Delete(c.ntcpclient)
+	sessionRegistry.DeleteUser(1)
+}
+
+func TestOpResumeInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	prev := newTestClient("10.0.2.1")
+	session, token := agree(assert, prev, 2)
+	defer ntcpRegistry.Delete(prev.ntcpclient)
+	defer sessionRegistry.DeleteUser(2)
+
+	var tests = []struct {
+		Name    string
+		Session string
+		Token   string
+		Error   string
+	}{
+		{"Test01: unknown session", "unknown", token, "unknown_resumetoken"},
+		{"Test02: wrong token", session, "wrong", "unknown_resumetoken"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			c := newTestClient("10.0.2.2")
+			assert.Equal(tt.Error, resume(assert, c, tt.Session, tt.Token))
+			assert.False(c.Authenticated)
+			assert.False(prev.closed())
+		})
+	}
+}
+
+func TestSessionRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	c := newTestClient("10.0.3.1")
+	c.UserID = 3
+
+	first, firstToken, err := sessionRegistry.Open(c.ntcpclient)
+	assert.NoError(err)
+
+	// opening another session of the user invalidates the first one
+	second, secondToken, err := sessionRegistry.Open(c.ntcpclient)
+	assert.NoError(err)
+	assert.Nil(sessionRegistry.Get(first.ID))
+	assert.False(sessionRegistry.Take(first, firstToken))
+
+	// a login invalidates all sessions of the user
+	sessionRegistry.DeleteUser(3)
+	assert.Nil(sessionRegistry.Get(second.ID))
+	assert.False(sessionRegistry.Take(second, secondToken))
+}
+
+func TestNtcpRegistryReplace(t *testing.T) {
+	assert := assert.New(t)
+
+	stale := newTestClient("10.0.4.1")
+	stale.UserID = 3
+	assert.NoError(ntcpRegistry.Put(stale.ntcpclient))
+
+	c := newTestClient("10.0.4.2")
+	c.UserID = 3
+	assert.Error(ntcpRegistry.Put(c.ntcpclient))
+
+	assert.Equal(stale.ntcpclient, ntcpRegistry.Replace(c.ntcpclient))
+	assert.Equal(c.ntcpclient, ntcpRegistry.Get(3))
+
+	// the stale connection must not remove it's replacement
+	assert.False(ntcpRegistry.Delete(stale.ntcpclient))
+	assert.True(ntcpRegistry.Delete(c.ntcpclient))
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"strconv"
-	"time"
 
 	"github.com/vikebot/vbgs/vbge"
 )
@@ -15,20 +14,29 @@ type rotatePacket struct {
 	Obj  rotateObj `json:"obj"`
 }
 
-func opRotate(c *ntcpclient, packet rotatePacket) {
-	// c.Player.Rl.Rotate.Take()
-	time.Sleep(500 * time.Millisecond)
-
-	if packet.Obj.Angle == nil {
-		c.Respond("Invalid packet. '.obj.angle' missing")
-		return
-	}
+func init() {
+	ops.Register(&op{
+		Name:      "rotate",
+		NewPacket: func() interface{} { return &rotatePacket{} },
+		Validate: func(p interface{}) *opError {
+			angle := p.(*rotatePacket).Obj.Angle
+			if angle == nil {
+				return errInvalidPacket.New("Invalid packet. '.obj.angle' missing").With("field", ".obj.angle")
+			}
+			if !vbge.IsAngle(*angle) {
+				return errInvalidPacket.Newf("Invalid packet. '%s' is not a valid value for '.obj.angle'", *angle).
+					With("field", ".obj.angle").
+					With("value", *angle)
+			}
+			return nil
+		},
+		Cooldown: func(c *ntcpclient) { c.Player.Rl.Rotate.Take() },
+		Handler:  func(c *ntcpclient, p interface{}) { opRotate(c, *p.(*rotatePacket)) },
+	})
+}
 
+func opRotate(c *ntcpclient, packet rotatePacket) {
 	angle := *packet.Obj.Angle
-	if !vbge.IsAngle(angle) {
-		c.RespondFmt("Invalid packet. '%s' is not a valid value for '.obj.angle'", angle)
-		return
-	}
 
 	ngl := c.Player.Rotate(angle)
 	c.RespondNil()
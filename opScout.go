@@ -2,7 +2,6 @@ package main
 
 import (
 	"strconv"
-	"time"
 
 	"github.com/vikebot/vbgs/vbge"
 )
@@ -20,20 +19,30 @@ type scoutResponse struct {
 	Counter int `json:"counter"`
 }
 
-func opScout(c *ntcpclient, packet scoutPacket) {
-	// c.Player.Rl.Scout.Take()
-	time.Sleep(500 * time.Millisecond)
-
-	if packet.Obj.Distance == nil {
-		c.Respond("Invalid packet. `obj.distance' missing")
-		return
-	}
+func init() {
+	ops.Register(&op{
+		Name:      "scout",
+		NewPacket: func() interface{} { return &scoutPacket{} },
+		Validate: func(p interface{}) *opError {
+			distance := p.(*scoutPacket).Obj.Distance
+			if distance == nil {
+				return errInvalidPacket.New("Invalid packet. `obj.distance' missing").With("field", ".obj.distance")
+			}
+			if !vbge.IsDistance(*distance) {
+				return errInvalidPacket.Newf("Invalid packet. '%d' is not a valid value for '.obj.distance'", *distance).
+					With("field", ".obj.distance").
+					With("value", *distance)
+			}
+			return nil
+		},
+		Cooldown: func(c *ntcpclient) { c.Player.Rl.Scout.Take() },
+		Handler:  func(c *ntcpclient, p interface{}) { opScout(c, *p.(*scoutPacket)) },
+		Passive:  true,
+	})
+}
 
+func opScout(c *ntcpclient, packet scoutPacket) {
 	distance := *packet.Obj.Distance
-	if !vbge.IsDistance(distance) {
-		c.RespondFmt("Invalid packet. '%s' is not a valid value for '.obj.distance'", strconv.Itoa(*packet.Obj.Distance))
-		return
-	}
 
 	counter, ngl := c.Player.Scout(distance)
 
@@ -0,0 +1,63 @@
+package main
+
+type subscribeObj struct {
+	Events *[]string `json:"events"`
+}
+
+type subscribePacket struct {
+	Type string       `json:"type"`
+	Obj  subscribeObj `json:"obj"`
+}
+
+type subscribeResponse struct {
+	Events []string `json:"events"`
+}
+
+func init() {
+	ops.Register(&op{
+		Name:      "subscribe",
+		NewPacket: func() interface{} { return &subscribePacket{} },
+		Validate: func(p interface{}) *opError {
+			events := p.(*subscribePacket).Obj.Events
+			if events == nil {
+				return nil
+			}
+			for _, e := range *events {
+				if !gameEvents[e] {
+					return errInvalidPacket.Newf("Invalid packet. '%s' is not a valid value for '.obj.events'", e).
+						With("field", ".obj.events").
+						With("value", e)
+				}
+			}
+			return nil
+		},
+		Handler:    func(c *ntcpclient, p interface{}) { opSubscribe(c, *p.(*subscribePacket)) },
+		Persistent: true,
+		Passive:    true,
+	})
+}
+
+// opSubscribe subscribes the client to server-pushed game events. If no
+// events are specified the client is subscribed to all of them.
+func opSubscribe(c *ntcpclient, packet subscribePacket) {
+	events := map[string]bool{}
+	if packet.Obj.Events == nil || len(*packet.Obj.Events) == 0 {
+		for e := range gameEvents {
+			events[e] = true
+		}
+	} else {
+		for _, e := range *packet.Obj.Events {
+			events[e] = true
+		}
+	}
+
+	c.subscribeEvents(events)
+
+	resp := subscribeResponse{
+		Events: make([]string, 0, len(events)),
+	}
+	for e := range events {
+		resp.Events = append(resp.Events, e)
+	}
+	c.RespondObj(&resp)
+}
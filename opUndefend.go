@@ -1,7 +1,5 @@
 package main
 
-import "time"
-
 type undefendObj struct {
 }
 
@@ -10,13 +8,19 @@ type undefendPacket struct {
 	Obj  undefendObj `json:"obj"`
 }
 
-func opUndefend(c *ntcpclient, packtet undefendPacket) {
-	// c.Player.Rl.Defend.Take()
-	time.Sleep(1000 * time.Millisecond)
+func init() {
+	ops.Register(&op{
+		Name:      "undefend",
+		NewPacket: func() interface{} { return &undefendPacket{} },
+		Cooldown:  func(c *ntcpclient) { c.Player.Rl.Defend.Take() },
+		Handler:   func(c *ntcpclient, p interface{}) { opUndefend(c, *p.(*undefendPacket)) },
+	})
+}
 
+func opUndefend(c *ntcpclient, packtet undefendPacket) {
 	ng, err := c.Player.Undefend()
 	if err != nil {
-		c.Respond(err.Error())
+		c.RespondErr(gameError(err))
 		return
 	}
 	c.RespondNil()
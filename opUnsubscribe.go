@@ -0,0 +1,26 @@
+package main
+
+type unsubscribeObj struct {
+}
+
+type unsubscribePacket struct {
+	Type string         `json:"type"`
+	Obj  unsubscribeObj `json:"obj"`
+}
+
+func init() {
+	ops.Register(&op{
+		Name:       "unsubscribe",
+		NewPacket:  func() interface{} { return &unsubscribePacket{} },
+		Handler:    func(c *ntcpclient, p interface{}) { opUnsubscribe(c, *p.(*unsubscribePacket)) },
+		Persistent: true,
+		Passive:    true,
+	})
+}
+
+// opUnsubscribe cancels the client's subscription to server-pushed game
+// events.
+func opUnsubscribe(c *ntcpclient, packet unsubscribePacket) {
+	c.unsubscribeEvents()
+	c.RespondNil()
+}
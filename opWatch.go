@@ -12,9 +12,17 @@ type watchResponse struct {
 	HealthMatrix [][]int `json:"health_matrix"`
 }
 
-func opWatch(c *ntcpclient, packet watchPacket) {
-	c.Player.Rl.Watch.Take()
+func init() {
+	ops.Register(&op{
+		Name:      "watch",
+		NewPacket: func() interface{} { return &watchPacket{} },
+		Cooldown:  func(c *ntcpclient) { c.Player.Rl.Watch.Take() },
+		Handler:   func(c *ntcpclient, p interface{}) { opWatch(c, *p.(*watchPacket)) },
+		Passive:   true,
+	})
+}
 
+func opWatch(c *ntcpclient, packet watchPacket) {
 	matrix, _ := c.Player.Watch()
 
 	c.RespondObj(&watchResponse{
@@ -2,7 +2,6 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
 
 	"go.uber.org/zap"
 )
@@ -15,7 +14,9 @@ func packetHandler(c *ntcpclient, data []byte) {
 		plainBuf, err := c.Crypt.DecryptBase64(data)
 		if err != nil {
 			c.Log.Warn("failed to decrypt cipher", zap.Error(err))
-			c.Respond("Invalid cipher text - unable to decrypt")
+			metricPacketFailures.WithLabelValues("decrypt").Inc()
+			c.RespondErr(errInvalidCipher.New("Invalid cipher text - unable to decrypt"))
+			c.violation("undecryptable cipher")
 			return
 		}
 		data = plainBuf
@@ -30,23 +31,28 @@ func packetHandler(c *ntcpclient, data []byte) {
 	var packet typePacket
 	err := json.Unmarshal(data, &packet)
 	if err != nil {
-		c.Respond("Invalid JSON syntax")
+		metricPacketFailures.WithLabelValues("json").Inc()
+		c.RespondErr(errInvalidJSON.New("Invalid JSON syntax"))
 		return
 	}
 	if packet.Type == nil {
-		c.Respond("Invalid packet. '.type' missing")
+		c.RespondErr(errInvalidPacket.New("Invalid packet. '.type' missing").With("field", ".type"))
 		return
 	}
 
 	// Check for correct packet count
 	if c.IsEncrypted {
 		if packet.Pc == nil {
-			c.Respond("Invalid packet. '.pc' missing")
+			metricPacketFailures.WithLabelValues("pc_missing").Inc()
+			c.RespondErr(errInvalidPacket.New("Invalid packet. '.pc' missing").With("field", ".pc"))
+			c.violation("missing pc")
 			return
 		}
 		c.Pc++
 		if *packet.Pc != c.Pc {
-			c.Respond("Protocol mismatch. '.pc' value not increased")
+			metricPacketFailures.WithLabelValues("pc_mismatch").Inc()
+			c.RespondErr(errPcMismatch.New("Protocol mismatch. '.pc' value not increased"))
+			c.violation("invalid pc")
 			return
 		}
 	}
@@ -58,29 +64,43 @@ func packetHandler(c *ntcpclient, data []byte) {
 	if !c.Authenticated {
 		notBefore := "You aren't allowed to send any packet type previous to a successful %q"
 
-		if !c.LoginDone && *packet.Type != "login" {
-			c.Respond(fmt.Sprintf(notBefore, "login"))
+		if !c.LoginDone && *packet.Type != "login" && *packet.Type != "resume" {
+			c.RespondErr(errHandshakeOrder.Newf(notBefore, "login").With("expected", "login"))
 			return
 		}
 		if c.LoginDone && !c.ClienthelloDone && *packet.Type != "clienthello" {
-			c.Respond(fmt.Sprintf(notBefore, "clienthello"))
+			c.RespondErr(errHandshakeOrder.Newf(notBefore, "clienthello").With("expected", "clienthello"))
 			return
 		}
 		if c.LoginDone && c.ClienthelloDone && !c.AgreeconnDone && *packet.Type != "agreeconn" {
-			c.Respond(fmt.Sprintf(notBefore, "agreeconn"))
+			c.RespondErr(errHandshakeOrder.Newf(notBefore, "agreeconn").With("expected", "agreeconn"))
 			return
 		}
 	}
 
+	// A session can only be resumed on a fresh connection
+	if c.LoginDone && *packet.Type == "resume" {
+		c.RespondErr(errHandshakeOrder.New("Protocol mismatch. 'resume' is only allowed as first packet"))
+		return
+	}
+
 	// Check if client has previously sent packets that are only allowed once
 	if (c.LoginDone && *packet.Type == "login") ||
 		(c.ClienthelloDone && *packet.Type == "clienthello") ||
 		(c.AgreeconnDone && *packet.Type == "agreeconn") {
 		c.CurType = *packet.Type
-		c.RespondFmt("Protocol mismatch. '%s' already done", *packet.Type)
+		c.RespondErr(errAlreadyDone.Newf("Protocol mismatch. '%s' already done", *packet.Type))
 		return
 	}
 
+	// Ops of authenticated users are serialized with the user's ops from
+	// other transports (e.g. http)
+	if c.Authenticated {
+		lock := lockRegistry.Get(c.UserID)
+		lock.Lock()
+		defer lock.Unlock()
+	}
+
 	// Dispatch the current notification
 	dispatch(c, data, packet)
 }
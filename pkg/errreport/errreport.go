@@ -0,0 +1,116 @@
+// Package errreport forwards log entries to an error-reporting service. The
+// zapcore.Core returned by NewCore can be teed together with the other cores
+// of a logger, so all `Error` (and more severe) entries get reported
+// including their fields and stack traces.
+package errreport
+
+import (
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// flushTimeout is the maximum time waited for pending reports before the
+// process might exit (e.g. after a `Fatal` entry).
+const flushTimeout = 2 * time.Second
+
+// Report is a single log entry prepared for reporting.
+type Report struct {
+	Time    time.Time
+	Level   zapcore.Level
+	Logger  string
+	Message string
+
+	// Stack contains the stack trace of the entry or of a field whose key
+	// ends with "stack" (e.g. `zap.Stack("recoverd_stack")`)
+	Stack string
+
+	// UserID is taken from the "user_id" field if present
+	UserID string
+
+	// Fields contains all remaining fields of the entry
+	Fields map[string]interface{}
+}
+
+// Reporter sends reports to an error-reporting service. Implementations must
+// be safe for concurrent use.
+type Reporter interface {
+	// Report sends r. It mustn't block for long, so sending asynchronously is
+	// encouraged
+	Report(r Report)
+
+	// Flush waits till all pending reports are sent or timeout exceeded. It
+	// reports whether all reports have been sent
+	Flush(timeout time.Duration) bool
+}
+
+type core struct {
+	zapcore.LevelEnabler
+	reporter Reporter
+	fields   []zapcore.Field
+}
+
+// NewCore returns a zapcore.Core reporting all entries enabled by enab to r.
+func NewCore(enab zapcore.LevelEnabler, r Reporter) zapcore.Core {
+	return &core{
+		LevelEnabler: enab,
+		reporter:     r,
+	}
+}
+
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	clone.fields = append(clone.fields, c.fields...)
+	clone.fields = append(clone.fields, fields...)
+	return &clone
+}
+
+func (c *core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *core) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	r := Report{
+		Time:    ent.Time,
+		Level:   ent.Level,
+		Logger:  ent.LoggerName,
+		Message: ent.Message,
+		Stack:   ent.Stack,
+		Fields:  enc.Fields,
+	}
+	for k, v := range r.Fields {
+		switch {
+		case k == "user_id":
+			r.UserID = toString(v)
+		case strings.HasSuffix(k, "stack") && r.Stack == "":
+			r.Stack = toString(v)
+			delete(r.Fields, k)
+		}
+	}
+
+	c.reporter.Report(r)
+
+	// the process is likely to exit after the entry has been written
+	if ent.Level > zapcore.ErrorLevel {
+		c.reporter.Flush(flushTimeout)
+	}
+	return nil
+}
+
+func (c *core) Sync() error {
+	c.reporter.Flush(flushTimeout)
+	return nil
+}
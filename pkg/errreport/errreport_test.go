@@ -0,0 +1,34 @@
+package errreport
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestCore_reportsErrors(t *testing.T) {
+	m := NewMemory()
+	log := zap.New(NewCore(zapcore.ErrorLevel, m))
+
+	ctx := log.With(zap.Int("user_id", 42))
+	ctx.Info("ignored")
+	ctx.Warn("ignored")
+	ctx.Error("recoverd from panic",
+		zap.Error(errors.New("boom")),
+		zap.Stack("recoverd_stack"))
+
+	reports := m.Reports()
+	if !assert.Len(t, reports, 1) {
+		return
+	}
+	r := reports[0]
+	assert.Equal(t, zapcore.ErrorLevel, r.Level)
+	assert.Equal(t, "recoverd from panic", r.Message)
+	assert.Equal(t, "42", r.UserID)
+	assert.Equal(t, "boom", r.Fields["error"])
+	assert.Contains(t, r.Stack, "TestCore_reportsErrors")
+	assert.NotContains(t, r.Fields, "recoverd_stack")
+}
@@ -0,0 +1,41 @@
+package errreport
+
+import (
+	"sync"
+	"time"
+)
+
+// Memory is a Reporter that keeps all reports in memory. It's a local
+// stand-in for a real error-reporting service (e.g. in tests).
+type Memory struct {
+	reports []Report
+	baton   sync.Mutex
+}
+
+// NewMemory returns an empty Memory reporter.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Report stores r.
+func (m *Memory) Report(r Report) {
+	m.baton.Lock()
+	defer m.baton.Unlock()
+
+	m.reports = append(m.reports, r)
+}
+
+// Flush is a no-op, because reports are stored synchronously.
+func (m *Memory) Flush(timeout time.Duration) bool {
+	return true
+}
+
+// Reports returns a copy of all stored reports.
+func (m *Memory) Reports() []Report {
+	m.baton.Lock()
+	defer m.baton.Unlock()
+
+	reports := make([]Report, len(m.reports))
+	copy(reports, m.reports)
+	return reports
+}
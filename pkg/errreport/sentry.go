@@ -0,0 +1,69 @@
+package errreport
+
+import (
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"go.uber.org/zap/zapcore"
+)
+
+// Sentry is a Reporter sending reports to Sentry.
+type Sentry struct {
+	hub *sentry.Hub
+}
+
+// NewSentry creates a Sentry reporter for the project referenced by dsn.
+func NewSentry(dsn, environment, release string) (*Sentry, error) {
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         dsn,
+		Environment: environment,
+		Release:     release,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sentry{
+		hub: sentry.NewHub(client, sentry.NewScope()),
+	}, nil
+}
+
+// Report sends r asynchronously to Sentry.
+func (s *Sentry) Report(r Report) {
+	event := sentry.NewEvent()
+	event.Level = sentryLevel(r.Level)
+	event.Logger = r.Logger
+	event.Message = r.Message
+	event.Timestamp = r.Time
+	event.Extra = r.Fields
+	if r.Stack != "" {
+		event.Extra["stacktrace"] = r.Stack
+	}
+	if r.UserID != "" {
+		event.User = sentry.User{
+			ID: r.UserID,
+		}
+	}
+
+	s.hub.CaptureEvent(event)
+}
+
+// Flush waits till all reports are sent to Sentry or timeout exceeded.
+func (s *Sentry) Flush(timeout time.Duration) bool {
+	return s.hub.Flush(timeout)
+}
+
+func sentryLevel(lvl zapcore.Level) sentry.Level {
+	switch lvl {
+	case zapcore.DebugLevel:
+		return sentry.LevelDebug
+	case zapcore.InfoLevel:
+		return sentry.LevelInfo
+	case zapcore.WarnLevel:
+		return sentry.LevelWarning
+	case zapcore.ErrorLevel:
+		return sentry.LevelError
+	default:
+		return sentry.LevelFatal
+	}
+}
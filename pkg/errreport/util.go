@@ -0,0 +1,10 @@
+package errreport
+
+import "fmt"
+
+func toString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,202 @@
+package mapimg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	// supported image formats
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// MaxImportSize is the maximum width and height (in blocks) of imported
+// maps.
+const MaxImportSize = 2000
+
+// MaxImportPixels is the maximum number of pixels of an imported image.
+// Larger images are rejected before their pixels are decoded.
+const MaxImportPixels = 64 << 20
+
+// ImportOptions configure Import. Zero values use the defaults.
+type ImportOptions struct {
+	// Palette defaults to DefaultPalette
+	Palette Palette
+	// TileSize is the width and height of a block in pixel (default 1). The
+	// average color of a tile is used, so small brush strokes or
+	// compression artifacts don't matter
+	TileSize int
+	// Width and Height are the expected size of the map in blocks. Zero
+	// accepts any size
+	Width  int
+	Height int
+}
+
+// Decode reads an image (PNG, GIF or JPEG). Only the image's header is read
+// before the size is checked, so oversized images are rejected without
+// allocating their pixels. tileSize is the same as ImportOptions.TileSize.
+func Decode(r io.Reader, tileSize int) (image.Image, error) {
+	if tileSize <= 0 {
+		tileSize = 1
+	}
+
+	var header bytes.Buffer
+	conf, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+	if err != nil {
+		return nil, err
+	}
+	if conf.Width*conf.Height > MaxImportPixels {
+		return nil, fmt.Errorf("mapimg: image size %dx%d exceeds %d pixels", conf.Width, conf.Height, MaxImportPixels)
+	}
+	err = checkSize(conf.Width/tileSize, conf.Height/tileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(io.MultiReader(&header, r))
+	return img, err
+}
+
+func checkSize(width, height int) error {
+	if width > MaxImportSize || height > MaxImportSize {
+		return fmt.Errorf("mapimg: map size %dx%d exceeds %dx%d", width, height, MaxImportSize, MaxImportSize)
+	}
+	return nil
+}
+
+// Import converts img into a map. Each tile becomes the blocktype whose
+// palette color is nearest to the tile's color. Images with transparent
+// pixels are rejected, because transparency has no blocktype.
+func Import(img image.Image, opt ImportOptions) ([][]string, error) {
+	if opt.TileSize <= 0 {
+		opt.TileSize = 1
+	}
+	if opt.Palette == nil {
+		opt.Palette = DefaultPalette
+	}
+	if len(opt.Palette) == 0 {
+		return nil, errors.New("mapimg: empty palette")
+	}
+
+	b := img.Bounds()
+	if b.Dx()%opt.TileSize != 0 || b.Dy()%opt.TileSize != 0 {
+		return nil, fmt.Errorf("mapimg: image size %dx%d isn't a multiple of the tile size %d", b.Dx(), b.Dy(), opt.TileSize)
+	}
+	width, height := b.Dx()/opt.TileSize, b.Dy()/opt.TileSize
+	if width == 0 || height == 0 {
+		return nil, errors.New("mapimg: empty image")
+	}
+	if err := checkSize(width, height); err != nil {
+		return nil, err
+	}
+	if opt.Width != 0 && width != opt.Width {
+		return nil, fmt.Errorf("mapimg: map width %d doesn't match the expected width %d", width, opt.Width)
+	}
+	if opt.Height != 0 && height != opt.Height {
+		return nil, fmt.Errorf("mapimg: map height %d doesn't match the expected height %d", height, opt.Height)
+	}
+
+	// sorted blocktypes make ties deterministic
+	blocktypes := make([]string, 0, len(opt.Palette))
+	for bt := range opt.Palette {
+		blocktypes = append(blocktypes, bt)
+	}
+	sort.Strings(blocktypes)
+
+	blocks := make([][]string, height)
+	for y := range blocks {
+		blocks[y] = make([]string, width)
+		for x := range blocks[y] {
+			c, opaque := averageColor(img, image.Rect(
+				b.Min.X+x*opt.TileSize, b.Min.Y+y*opt.TileSize,
+				b.Min.X+(x+1)*opt.TileSize, b.Min.Y+(y+1)*opt.TileSize))
+			if !opaque {
+				return nil, fmt.Errorf("mapimg: block %d,%d contains transparent pixels", x, y)
+			}
+			blocks[y][x] = opt.Palette.nearest(c, blocktypes)
+		}
+	}
+	return blocks, nil
+}
+
+// nearest returns the blocktype with the color nearest to c.
+func (p Palette) nearest(c color.RGBA, blocktypes []string) string {
+	best, bestDist := "", -1
+	for _, bt := range blocktypes {
+		pc := p[bt]
+		dr, dg, db := int(c.R)-int(pc.R), int(c.G)-int(pc.G), int(c.B)-int(pc.B)
+		d := dr*dr + dg*dg + db*db
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = bt, d
+		}
+	}
+	return best
+}
+
+// averageColor returns the average color of r inside img. opaque is false if
+// any of the pixels isn't fully opaque.
+func averageColor(img image.Image, r image.Rectangle) (c color.RGBA, opaque bool) {
+	var sr, sg, sb, n uint32
+	opaque = true
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			cr, cg, cb, ca := img.At(x, y).RGBA()
+			if ca != 0xffff {
+				opaque = false
+			}
+			sr += cr >> 8
+			sg += cg >> 8
+			sb += cb >> 8
+			n++
+		}
+	}
+	return color.RGBA{uint8(sr / n), uint8(sg / n), uint8(sb / n), 255}, opaque
+}
+
+// MarshalJSON encodes the colors as hex strings (e.g. "#5aa03c").
+func (p Palette) MarshalJSON() ([]byte, error) {
+	m := make(map[string]string, len(p))
+	for bt, c := range p {
+		m[bt] = fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON decodes a palette of hex colors (e.g. "#5aa03c").
+func (p *Palette) UnmarshalJSON(data []byte) error {
+	var m map[string]string
+	err := json.Unmarshal(data, &m)
+	if err != nil {
+		return err
+	}
+
+	*p = make(Palette, len(m))
+	for bt, hex := range m {
+		c, err := parseHexColor(hex)
+		if err != nil {
+			return fmt.Errorf("mapimg: color of %s: %v", bt, err)
+		}
+		(*p)[bt] = c
+	}
+	return nil
+}
+
+func parseHexColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, nil
+}
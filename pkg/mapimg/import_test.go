@@ -0,0 +1,180 @@
+package mapimg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImport_roundtrip(t *testing.T) {
+	assert := assert.New(t)
+
+	blocks := [][]string{
+		{"grass", "water", "lava", "tree"},
+		{"dirt", "mountain", "swamp", "grass"},
+	}
+	img := Render(blocks, Options{TileSize: 5})
+
+	imported, err := Import(img, ImportOptions{TileSize: 5, Width: 4, Height: 2})
+	assert.NoError(err)
+	assert.Equal(blocks, imported)
+}
+
+func TestImport_nearestColor(t *testing.T) {
+	assert := assert.New(t)
+
+	palette := Palette{
+		"grass": {0, 200, 0, 255},
+		"water": {0, 0, 200, 255},
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.SetRGBA(0, 0, color.RGBA{30, 170, 40, 255})
+	img.SetRGBA(1, 0, color.RGBA{20, 60, 150, 255})
+
+	blocks, err := Import(img, ImportOptions{Palette: palette})
+	assert.NoError(err)
+	assert.Equal([][]string{{"grass", "water"}}, blocks)
+}
+
+func TestImport_gif(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	src := Render([][]string{{"grass", "water"}}, Options{TileSize: 2})
+	assert.NoError(gif.Encode(&buf, src, nil))
+
+	img, err := Decode(&buf, 2)
+	assert.NoError(err)
+	blocks, err := Import(img, ImportOptions{TileSize: 2})
+	assert.NoError(err)
+	assert.Equal([][]string{{"grass", "water"}}, blocks)
+}
+
+func TestImport_invalidSize(t *testing.T) {
+	assert := assert.New(t)
+
+	img := image.NewRGBA(image.Rect(0, 0, 10, 6))
+
+	var tests = []struct {
+		Name string
+		Opt  ImportOptions
+	}{
+		{"Test01: not a multiple of the tile size", ImportOptions{TileSize: 4}},
+		{"Test02: unexpected width", ImportOptions{Width: 11}},
+		{"Test03: unexpected height", ImportOptions{TileSize: 2, Height: 6}},
+		{"Test04: empty palette", ImportOptions{Palette: Palette{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			_, err := Import(img, tt.Opt)
+			assert.Error(err)
+		})
+	}
+}
+
+func TestPalette_JSON(t *testing.T) {
+	assert := assert.New(t)
+
+	var p Palette
+	assert.NoError(json.Unmarshal([]byte(`{"grass":"#5aa03c","water":"3264c8"}`), &p))
+	assert.Equal(Palette{"grass": {0x5a, 0xa0, 0x3c, 255}, "water": {0x32, 0x64, 0xc8, 255}}, p)
+
+	buf, err := json.Marshal(p)
+	assert.NoError(err)
+	assert.JSONEq(`{"grass":"#5aa03c","water":"#3264c8"}`, string(buf))
+
+	assert.Error(json.Unmarshal([]byte(`{"grass":"green"}`), &p))
+}
+
+// pngHeader returns the beginning of a png of the given size. It's enough
+// for DecodeConfig, but decoding the pixels fails.
+func pngHeader(w, h int) []byte {
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(w))
+	binary.BigEndian.PutUint32(ihdr[8:], uint32(h))
+	ihdr[12] = 8 // bit depth
+	ihdr[13] = 0 // grayscale
+
+	buf := []byte("\x89PNG\r\n\x1a\n")
+	buf = append(buf, 0, 0, 0, 13)
+	buf = append(buf, ihdr...)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(ihdr))
+	return append(buf, crc...)
+}
+
+func TestDecode_size(t *testing.T) {
+	encode := func(w, h int) []byte {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	var tests = []struct {
+		Name     string
+		Image    []byte
+		TileSize int
+		Err      string
+	}{
+		{"Test01: maximum size", encode(MaxImportSize, 1), 1, ""},
+		{"Test02: too many blocks", encode(MaxImportSize+1, 1), 1, "exceeds 2000x2000"},
+		{"Test03: larger tiles", encode(2*MaxImportSize, 1), 2, ""},
+		{"Test04: too many pixels", pngHeader(MaxImportPixels/1000+1000, 1000), 1000, "pixels"},
+		{"Test05: huge image", pngHeader(50000, 50000), 1, "pixels"},
+		{"Test06: no image", []byte("no image"), 1, "unknown format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			img, err := Decode(bytes.NewReader(tt.Image), tt.TileSize)
+			if tt.Err != "" {
+				if assert.Error(err) {
+					assert.Contains(err.Error(), tt.Err)
+				}
+				return
+			}
+			if assert.NoError(err) {
+				assert.False(img.Bounds().Empty())
+			}
+		})
+	}
+}
+
+func TestImport_transparent(t *testing.T) {
+	var tests = []struct {
+		Name  string
+		Alpha uint8
+		Err   bool
+	}{
+		{"Test01: opaque", 255, false},
+		{"Test02: translucent", 128, true},
+		{"Test03: transparent", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+			img.SetNRGBA(0, 0, color.NRGBA{0, 200, 0, 255})
+			img.SetNRGBA(1, 0, color.NRGBA{0, 200, 0, tt.Alpha})
+
+			_, err := Import(img, ImportOptions{})
+			assert.Equal(tt.Err, err != nil, "%v", err)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+// Package mapimg converts vikebot maps (matrices of blocktypes) into images
+// and back. Rendered images are used as previews of maps, replays and live
+// rounds. Each block is drawn as a single colored tile.
+package mapimg
+
+import "image/color"
+
+// Palette maps blocktypes to the color of their tiles.
+type Palette map[string]color.RGBA
+
+// DefaultPalette contains a color for all blocktypes of vbge.
+var DefaultPalette = Palette{
+	"swamp":          {79, 92, 54, 255},
+	"stonetile":      {150, 150, 150, 255},
+	"dirt":           {120, 85, 50, 255},
+	"dirt_light":     {170, 130, 85, 255},
+	"grass":          {90, 160, 60, 255},
+	"lava":           {230, 80, 20, 255},
+	"lavarock":       {80, 40, 30, 255},
+	"water":          {50, 110, 200, 255},
+	"endofmap":       {0, 0, 0, 255},
+	"fog":            {200, 200, 210, 255},
+	"mountain":       {110, 100, 95, 255},
+	"mountain_light": {150, 140, 130, 255},
+	"tree":           {30, 100, 40, 255},
+}
+
+// unknownColor is used for blocktypes missing in the palette, so they stand
+// out in the preview.
+var unknownColor = color.RGBA{255, 0, 255, 255}
+
+// Color returns the color of blocktype.
+func (p Palette) Color(blocktype string) color.RGBA {
+	c, ok := p[blocktype]
+	if !ok {
+		return unknownColor
+	}
+	return c
+}
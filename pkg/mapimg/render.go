@@ -0,0 +1,164 @@
+package mapimg
+
+import (
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+)
+
+// Player is a player drawn on top of the map.
+type Player struct {
+	ID string
+	X  int
+	Y  int
+	// WatchDir is drawn as line from the center towards the direction
+	// (north, east, south or west). It's optional.
+	WatchDir string
+}
+
+// Options configure Render. Zero values use the defaults.
+type Options struct {
+	// TileSize is the width and height of a block in pixel (default 8)
+	TileSize int
+	// Palette defaults to DefaultPalette
+	Palette Palette
+	// Grid draws the borders of all tiles
+	Grid bool
+	// Spawns are marked with a white frame
+	Spawns []image.Point
+	// Players are drawn as circles in a color derived from their ID
+	Players []Player
+}
+
+var (
+	gridColor    = color.RGBA{0, 0, 0, 60}
+	spawnColor   = color.RGBA{255, 255, 255, 255}
+	outlineColor = color.RGBA{0, 0, 0, 255}
+)
+
+// Render draws blocks (rows from north to south) as image.
+func Render(blocks [][]string, opt Options) *image.RGBA {
+	if opt.TileSize <= 0 {
+		opt.TileSize = 8
+	}
+	if opt.Palette == nil {
+		opt.Palette = DefaultPalette
+	}
+	ts := opt.TileSize
+
+	width := 0
+	for _, row := range blocks {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width*ts, len(blocks)*ts))
+
+	for y, row := range blocks {
+		for x, b := range row {
+			draw.Draw(img, tile(x, y, ts), &image.Uniform{opt.Palette.Color(b)}, image.Point{}, draw.Src)
+		}
+	}
+
+	if opt.Grid && ts >= 4 {
+		for y := range blocks {
+			for x := 0; x < width; x++ {
+				frame(img, tile(x, y, ts), 1, gridColor)
+			}
+		}
+	}
+
+	for _, s := range opt.Spawns {
+		frame(img, tile(s.X, s.Y, ts).Inset(ts/8), imax(1, ts/8), spawnColor)
+	}
+
+	for _, p := range opt.Players {
+		drawPlayer(img, p, ts)
+	}
+
+	return img
+}
+
+// Encode writes img as PNG.
+func Encode(w io.Writer, img image.Image) error {
+	return png.Encode(w, img)
+}
+
+// PlayerColor returns the color a player is drawn with. It only depends on
+// the ID, so a player keeps its color between frames.
+func PlayerColor(id string) color.RGBA {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	sum := h.Sum32()
+
+	// keep the colors bright, so they stand out from the blocks
+	return color.RGBA{
+		R: 128 + uint8(sum&0x7f),
+		G: 128 + uint8((sum>>8)&0x7f),
+		B: 128 + uint8((sum>>16)&0x7f),
+		A: 255,
+	}
+}
+
+func tile(x, y, ts int) image.Rectangle {
+	return image.Rect(x*ts, y*ts, (x+1)*ts, (y+1)*ts)
+}
+
+// frame draws the border of r with the width w.
+func frame(img *image.RGBA, r image.Rectangle, w int, c color.Color) {
+	u := &image.Uniform{c}
+	draw.Draw(img, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+w), u, image.Point{}, draw.Over)
+	draw.Draw(img, image.Rect(r.Min.X, r.Max.Y-w, r.Max.X, r.Max.Y), u, image.Point{}, draw.Over)
+	draw.Draw(img, image.Rect(r.Min.X, r.Min.Y+w, r.Min.X+w, r.Max.Y-w), u, image.Point{}, draw.Over)
+	draw.Draw(img, image.Rect(r.Max.X-w, r.Min.Y+w, r.Max.X, r.Max.Y-w), u, image.Point{}, draw.Over)
+}
+
+func drawPlayer(img *image.RGBA, p Player, ts int) {
+	c := PlayerColor(p.ID)
+	cx, cy := p.X*ts+ts/2, p.Y*ts+ts/2
+	r := float64(ts) * 0.4
+
+	bounds := tile(p.X, p.Y, ts).Intersect(img.Bounds())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			dx, dy := float64(x-cx)+0.5, float64(y-cy)+0.5
+			d := dx*dx + dy*dy
+			switch {
+			case d <= (r-1)*(r-1):
+				img.SetRGBA(x, y, c)
+			case d <= r*r:
+				img.SetRGBA(x, y, outlineColor)
+			}
+		}
+	}
+
+	dx, dy := 0, 0
+	switch p.WatchDir {
+	case "north":
+		dy = -1
+	case "east":
+		dx = 1
+	case "south":
+		dy = 1
+	case "west":
+		dx = -1
+	default:
+		return
+	}
+	for i := 0; i <= int(r); i++ {
+		x, y := cx+dx*i, cy+dy*i
+		if (image.Point{x, y}).In(img.Bounds()) {
+			img.SetRGBA(x, y, outlineColor)
+		}
+	}
+}
+
+func imax(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
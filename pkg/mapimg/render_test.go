@@ -0,0 +1,68 @@
+package mapimg
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	assert := assert.New(t)
+
+	blocks := [][]string{
+		{"grass", "water", "lava"},
+		{"dirt", "unknown", "tree"},
+	}
+	img := Render(blocks, Options{TileSize: 4})
+
+	assert.Equal(image.Rect(0, 0, 12, 8), img.Bounds())
+	assert.Equal(DefaultPalette["grass"], img.RGBAAt(1, 1))
+	assert.Equal(DefaultPalette["water"], img.RGBAAt(5, 1))
+	assert.Equal(DefaultPalette["tree"], img.RGBAAt(9, 5))
+	assert.Equal(unknownColor, img.RGBAAt(5, 5))
+}
+
+func TestRender_markers(t *testing.T) {
+	assert := assert.New(t)
+
+	blocks := [][]string{
+		{"grass", "grass"},
+		{"grass", "grass"},
+	}
+	img := Render(blocks, Options{
+		TileSize: 16,
+		Spawns:   []image.Point{{X: 1, Y: 0}},
+		Players:  []Player{{ID: "7", X: 0, Y: 1, WatchDir: "north"}},
+	})
+
+	// spawn frame is inset by TileSize/8
+	assert.Equal(spawnColor, img.RGBAAt(16+2, 2))
+	assert.Equal(DefaultPalette["grass"], img.RGBAAt(16+8, 8))
+
+	// player circle in the middle and the watch direction above it
+	assert.Equal(PlayerColor("7"), img.RGBAAt(10, 16+10))
+	assert.Equal(outlineColor, img.RGBAAt(8, 16+3))
+	assert.Equal(DefaultPalette["grass"], img.RGBAAt(0, 16))
+}
+
+func TestPlayerColor(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(PlayerColor("1"), PlayerColor("1"))
+	assert.NotEqual(PlayerColor("1"), PlayerColor("2"))
+}
+
+func TestEncode(t *testing.T) {
+	assert := assert.New(t)
+
+	img := Render([][]string{{"grass"}}, Options{})
+	var buf bytes.Buffer
+	assert.NoError(Encode(&buf, img))
+
+	decoded, err := png.Decode(&buf)
+	assert.NoError(err)
+	assert.Equal(image.Rect(0, 0, 8, 8), decoded.Bounds())
+}
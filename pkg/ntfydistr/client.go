@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/eapache/queue"
@@ -14,13 +15,19 @@ import (
 // a specific user. A Client can hold multiple subscribers (e.g. multiple
 // receivers for the user's events.
 type Client struct {
-	userID   string
-	q        *queue.Queue
-	qSync    sync.Mutex
-	subs     []*subscriber
-	subsSync sync.Mutex
+	userID    string
+	q         *queue.Queue
+	qSync     sync.Mutex
+	subs      []*subscriber
+	subsSync  sync.Mutex
+	flushHook atomic.Value
 }
 
+// FlushHook is called every time a client's runner flushed notifications to
+// it's subscribers. It can be used to collect metrics and must be safe for
+// concurrent use.
+type FlushHook func(userID string, notifications int)
+
 func newClient(userID string) *Client {
 	return &Client{
 		userID: userID,
@@ -44,8 +51,12 @@ func (c *Client) run(stop chan struct{}, log *zap.Logger) {
 	for {
 		select {
 		case <-stop:
-			// received stop signal from caller. return
+			// received stop signal from caller. flush all remaining
+			// notifications and release the subscribers
 			log.Info("received stop. exiting notification update loop")
+			tick.Stop()
+			c.dequeueAndSend(log)
+			c.removeAllSubs()
 			return
 		case <-tick.C:
 			c.dequeueAndSend(log)
@@ -75,6 +86,10 @@ func (c *Client) dequeueAndSend(log *zap.Logger) {
 		return
 	}
 
+	if h, ok := c.flushHook.Load().(FlushHook); ok && h != nil {
+		h(c.userID, len(notfs))
+	}
+
 	// marshal notifications slice
 	buf, err := json.Marshal(notfs)
 	if err != nil {
@@ -87,32 +102,80 @@ func (c *Client) dequeueAndSend(log *zap.Logger) {
 		c.subsSync.Lock()
 		defer c.subsSync.Unlock()
 
-		disconnSubs := []int{}
+		disconnSubs := []*subscriber{}
 
 		// send client notifications to all subscribers
-		for i, s := range c.subs {
+		for _, s := range c.subs {
 			disconnected := s.Send(buf, len(notfs))
 			if !disconnected {
-				return
+				continue
 			}
 
 			// prepare specific subscriber for closing
 			log.Debug("subscriber is disconnecting")
-			disconnSubs = append(disconnSubs, i)
-			close(s.stop)
+			disconnSubs = append(disconnSubs, s)
 		}
 
-		// start at the end of disconnSubs to delete them in a save way
-		for i := len(disconnSubs) - 1; i >= 0; i-- {
-			// subscriber has disconnected -> remove him from the subscriber
-			// list at index i
-			c.subs[i] = c.subs[len(c.subs)-1]
-			c.subs[len(c.subs)-1] = nil
-			c.subs = c.subs[:len(c.subs)-1]
+		for _, s := range disconnSubs {
+			c.removeSubUnsafe(s)
 		}
 	}()
 }
 
+// QueueLength returns the amount of notifications waiting for the next flush.
+func (c *Client) QueueLength() int {
+	c.qSync.Lock()
+	defer c.qSync.Unlock()
+
+	return c.q.Length()
+}
+
+// SubscriberCount returns the amount of currently active subscribers.
+func (c *Client) SubscriberCount() int {
+	c.subsSync.Lock()
+	defer c.subsSync.Unlock()
+
+	return len(c.subs)
+}
+
+// removeSub removes the subscriber from the subs list and releases the
+// blocking Sub call. Calling removeSub for an already removed subscriber is a
+// no-op.
+func (c *Client) removeSub(cr *subscriber) {
+	c.subsSync.Lock()
+	defer c.subsSync.Unlock()
+
+	c.removeSubUnsafe(cr)
+}
+
+// removeAllSubs removes all subscribers and releases their blocking Sub calls.
+func (c *Client) removeAllSubs() {
+	c.subsSync.Lock()
+	defer c.subsSync.Unlock()
+
+	for len(c.subs) > 0 {
+		c.removeSubUnsafe(c.subs[0])
+	}
+}
+
+// removeSubUnsafe is like removeSub but expects the caller to hold subsSync.
+func (c *Client) removeSubUnsafe(cr *subscriber) {
+	for i, s := range c.subs {
+		if s != cr {
+			continue
+		}
+
+		// subscriber has disconnected -> remove him from the subscriber
+		// list at index i
+		c.subs[i] = c.subs[len(c.subs)-1]
+		c.subs[len(c.subs)-1] = nil
+		c.subs = c.subs[:len(c.subs)-1]
+
+		close(cr.stop)
+		return
+	}
+}
+
 // UserID returns the user id of the user this client represents.
 func (c *Client) UserID() string {
 	return c.userID
@@ -124,6 +187,14 @@ func (c *Client) UserID() string {
 // indicates that this error was caused by a disconnect of the remote party.
 // Notifications are queued and send in regular intervals.
 func (c *Client) Sub(r Receiver, log *zap.Logger) {
+	c.SubUntil(r, nil, log)
+}
+
+// SubUntil is like Sub, but additionally removes the subscriber as soon as the
+// done channel is closed. This allows callers to unsubscribe receivers whose
+// remote party stopped responding, even if no notification is written to
+// them in the meantime. A nil done channel never fires.
+func (c *Client) SubUntil(r Receiver, done <-chan struct{}, log *zap.Logger) {
 	// Allocate receiver
 	cr := newSubscriber(r, make(chan struct{}), log)
 
@@ -153,7 +224,12 @@ func (c *Client) Sub(r Receiver, log *zap.Logger) {
 	log.Debug("added new subscriber to client")
 
 	// Block till this subscription stops
-	<-cr.stop
+	select {
+	case <-cr.stop:
+	case <-done:
+		log.Debug("subscription cancelled by caller")
+		c.removeSub(cr)
+	}
 }
 
 // Push takes any notificationType and data to construct the final
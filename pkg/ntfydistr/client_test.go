@@ -0,0 +1,117 @@
+package ntfydistr
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_SubUntil(t *testing.T) {
+	c := newClient("1")
+	done := make(chan struct{})
+	returned := make(chan struct{})
+
+	go func() {
+		c.SubUntil(funcToReceiver(func(_ []byte) (bool, error) {
+			return false, nil
+		}), done, newTestLog())
+		close(returned)
+	}()
+
+	// wait till the subscriber is live
+	for i := 0; ; i++ {
+		c.subsSync.Lock()
+		live := len(c.subs) == 1
+		c.subsSync.Unlock()
+		if live {
+			break
+		}
+		if i == 1000 {
+			t.Fatal("subscriber never got live")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(done)
+
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("SubUntil didn't return after done was closed")
+	}
+	assert.Len(t, c.subs, 0)
+}
+
+func TestClient_dequeueAndSendRemovesDisconnected(t *testing.T) {
+	c := newClient("1")
+	log := newTestLog()
+
+	ok := newSubscriber(funcToReceiver(func(_ []byte) (bool, error) {
+		return false, nil
+	}), make(chan struct{}), log)
+	disconn1 := newSubscriber(funcToReceiver(func(_ []byte) (bool, error) {
+		return true, errors.New("gone")
+	}), make(chan struct{}), log)
+	disconn2 := newSubscriber(funcToReceiver(func(_ []byte) (bool, error) {
+		return true, errors.New("gone")
+	}), make(chan struct{}), log)
+
+	c.addSub(disconn1, log)
+	c.addSub(ok, log)
+	c.addSub(disconn2, log)
+
+	c.Push("test", struct{}{}, log)
+	c.dequeueAndSend(log)
+
+	assert.Equal(t, []*subscriber{ok}, c.subs)
+
+	// stop channels of the removed subscribers must be closed
+	_, open := <-disconn1.stop
+	assert.False(t, open)
+	_, open = <-disconn2.stop
+	assert.False(t, open)
+}
+
+func TestClient_runFlushesOnStop(t *testing.T) {
+	c := newClient("1")
+	log := newTestLog()
+
+	var written [][]byte
+	s := newSubscriber(funcToReceiver(func(b []byte) (bool, error) {
+		written = append(written, b)
+		return false, nil
+	}), make(chan struct{}), log)
+	c.addSub(s, log)
+
+	stop := make(chan struct{})
+	close(stop)
+	c.Push("test", struct{}{}, log)
+	c.run(stop, log)
+
+	assert.Len(t, written, 1)
+	assert.Len(t, c.subs, 0)
+	_, open := <-s.stop
+	assert.False(t, open)
+}
+
+func TestClient_flushHook(t *testing.T) {
+	c := newClient("1")
+	log := newTestLog()
+
+	var flushed []int
+	c.flushHook.Store(FlushHook(func(userID string, n int) {
+		assert.Equal(t, "1", userID)
+		flushed = append(flushed, n)
+	}))
+
+	c.dequeueAndSend(log)
+	c.Push("test", struct{}{}, log)
+	c.Push("test", struct{}{}, log)
+	assert.Equal(t, 2, c.QueueLength())
+	c.dequeueAndSend(log)
+
+	assert.Equal(t, []int{2}, flushed)
+	assert.Equal(t, 0, c.QueueLength())
+}
@@ -29,6 +29,7 @@ func NewDistributor(allUserIDs []string, stop chan struct{}, log *zap.Logger) *D
 	d := &Distributor{
 		allUserIDs: allUserIDs,
 		clients:    make(map[string]*Client, len(allUserIDs)),
+		stop:       stop,
 	}
 
 	// create all clients
@@ -58,12 +59,31 @@ func NewDistributor(allUserIDs []string, stop chan struct{}, log *zap.Logger) *D
 // Close first waits for the signal of the stop channel provided during
 // NewDistributor. Next Close waits for all client update runners to finish.
 // As soon as Close returns all started goroutines from ntfydistr should be
-// stopped and all remaining messages sent to the subscribers.
+// stopped, all remaining messages sent to the subscribers and all blocking
+// Sub calls returned.
 func (d *Distributor) Close() {
 	<-d.stop
 	d.wg.Wait()
 }
 
+// SetFlushHook sets h as FlushHook of all clients. Passing nil removes a
+// previously set hook.
+func (d *Distributor) SetFlushHook(h FlushHook) {
+	d.clientsSync.RLock()
+	defer d.clientsSync.RUnlock()
+
+	for _, c := range d.clients {
+		c.flushHook.Store(h)
+	}
+}
+
+// UserIDs returns the IDs of all users the Distributor manages clients for.
+func (d *Distributor) UserIDs() []string {
+	ids := make([]string, len(d.allUserIDs))
+	copy(ids, d.allUserIDs)
+	return ids
+}
+
 // GetClient returns the Client if currently subscribed. If the client is not
 // subscribed nil will be returned. The method is safe for concurrent use.
 func (d *Distributor) GetClient(userID string) *Client {
@@ -15,11 +15,15 @@ type playerStats struct {
 
 type playersStats []playerStats
 
+// usernamesFromRoundID loads the usernames of all players in the round. Tests
+// replace it, so viewers can be served without database.
+var usernamesFromRoundID = vbdb.UsernamesFromRoundID
+
 // getPlayersStats returns the type playersStats which
 // is a slice of playerStats, it's used for getting
 // information of all players in the game
 func getPlayersStats() (ps playersStats, err error) {
-	usernames, success := vbdb.UsernamesFromRoundID(config.Battle.RoundID)
+	usernames, success := usernamesFromRoundID(config().Battle.RoundID)
 	if !success {
 		return ps, errors.New("unable to load usernames from db")
 	}
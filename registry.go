@@ -1,8 +1,11 @@
 package main
 
 import (
+	"encoding/base64"
 	"fmt"
 	"sync"
+
+	"github.com/vikebot/vbcore"
 )
 
 // ---------------------------------------------------------------------------
@@ -31,11 +34,130 @@ func (r *regntcp) Get(userID int) *ntcpclient {
 	return r.m[userID]
 }
 
-func (r *regntcp) Delete(c *ntcpclient) {
+// All returns a snapshot of all registered clients.
+func (r *regntcp) All() []*ntcpclient {
+	r.baton.Lock()
+	defer r.baton.Unlock()
+
+	all := make([]*ntcpclient, 0, len(r.m))
+	for _, c := range r.m {
+		all = append(all, c)
+	}
+	return all
+}
+
+// Replace registers c for it's user and returns the previously registered
+// client (if any), which is now stale and should be closed by the caller.
+func (r *regntcp) Replace(c *ntcpclient) (stale *ntcpclient) {
 	r.baton.Lock()
 	defer r.baton.Unlock()
 
+	stale = r.m[c.UserID]
+	r.m[c.UserID] = c
+
+	return stale
+}
+
+// Delete removes c from the registry. If another client has already taken
+// over the user's slot (e.g. through a resumed session) the registry is left
+// untouched. The return value reports whether c was removed.
+func (r *regntcp) Delete(c *ntcpclient) bool {
+	r.baton.Lock()
+	defer r.baton.Unlock()
+
+	if r.m[c.UserID] != c {
+		return false
+	}
 	delete(r.m, c.UserID)
+
+	return true
+}
+
+// ---------------------------------------------------------------------------
+
+// session is a resumable ntcp session. The token is only valid for a single
+// resume and is rotated every time the session is handed over to another
+// connection.
+type session struct {
+	ID     string
+	UserID int
+	Crypt  *vbcore.CryptoService
+	Client *ntcpclient
+
+	token string
+}
+
+type regsession struct {
+	m     map[string]*session
+	baton sync.Mutex
+}
+
+// Open starts a new session held by c. All previous sessions of c's user are
+// invalidated.
+func (r *regsession) Open(c *ntcpclient) (s *session, token string, err error) {
+	idBuf, err := vbcore.CryptoGenBytes(16)
+	if err != nil {
+		return nil, "", err
+	}
+	tokenBuf, err := vbcore.CryptoGenBytes(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s = &session{
+		ID:     base64.RawURLEncoding.EncodeToString(idBuf),
+		UserID: c.UserID,
+		Crypt:  c.Crypt,
+		Client: c,
+		token:  base64.RawURLEncoding.EncodeToString(tokenBuf),
+	}
+
+	r.baton.Lock()
+	defer r.baton.Unlock()
+
+	r.deleteUser(c.UserID)
+	r.m[s.ID] = s
+
+	return s, s.token, nil
+}
+
+// Get returns the session referenced by id or nil if the id is unknown.
+func (r *regsession) Get(id string) *session {
+	r.baton.Lock()
+	defer r.baton.Unlock()
+
+	return r.m[id]
+}
+
+// Take removes s from the registry if token is it's current token. It
+// reports whether the caller took over the session. Concurrent resumes of
+// the same session therefore only succeed once.
+func (r *regsession) Take(s *session, token string) bool {
+	r.baton.Lock()
+	defer r.baton.Unlock()
+
+	if r.m[s.ID] != s || !vbcore.CryptoCmpStr(s.token, token) {
+		return false
+	}
+	delete(r.m, s.ID)
+
+	return true
+}
+
+// DeleteUser invalidates all sessions of the user.
+func (r *regsession) DeleteUser(userID int) {
+	r.baton.Lock()
+	defer r.baton.Unlock()
+
+	r.deleteUser(userID)
+}
+
+func (r *regsession) deleteUser(userID int) {
+	for id, s := range r.m {
+		if s.UserID == userID {
+			delete(r.m, id)
+		}
+	}
 }
 
 // ---------------------------------------------------------------------------
@@ -59,6 +181,18 @@ func (r *regnws) Get(userID int) []*nwsclient {
 	return r.m[userID]
 }
 
+// All returns a snapshot of all registered clients.
+func (r *regnws) All() []*nwsclient {
+	r.baton.Lock()
+	defer r.baton.Unlock()
+
+	var all []*nwsclient
+	for _, cs := range r.m {
+		all = append(all, cs...)
+	}
+	return all
+}
+
 func (r *regnws) Delete(c *nwsclient) {
 	r.baton.Lock()
 	defer r.baton.Unlock()
@@ -90,8 +224,32 @@ func (r *regnws) Delete(c *nwsclient) {
 
 // ---------------------------------------------------------------------------
 
+// reglock contains a mutex per user that serializes the user's ops across
+// all transports.
+type reglock struct {
+	m     map[int]*sync.Mutex
+	baton sync.Mutex
+}
+
+// Get returns the mutex of the user.
+func (r *reglock) Get(userID int) *sync.Mutex {
+	r.baton.Lock()
+	defer r.baton.Unlock()
+
+	m, ok := r.m[userID]
+	if !ok {
+		m = &sync.Mutex{}
+		r.m[userID] = m
+	}
+	return m
+}
+
+// ---------------------------------------------------------------------------
+
 var ntcpRegistry regntcp
 var nwsRegistry regnws
+var sessionRegistry regsession
+var lockRegistry reglock
 
 func registryInit() {
 	ntcpRegistry = regntcp{
@@ -100,5 +258,11 @@ func registryInit() {
 	nwsRegistry = regnws{
 		m: map[int][]*nwsclient{},
 	}
-	log.Info("initialized registry storages for ntcpclient and nwsclient structs")
+	sessionRegistry = regsession{
+		m: map[string]*session{},
+	}
+	lockRegistry = reglock{
+		m: map[int]*sync.Mutex{},
+	}
+	log.Info("initialized registry storages for ntcpclient, nwsclient, session and lock structs")
 }
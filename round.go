@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/vikebot/vbgs/pkg/ntfydistr"
+	"go.uber.org/zap"
+)
+
+// roundPhase is the current phase of the round.
+type roundPhase int
+
+const (
+	phaseRunning roundPhase = iota
+	phasePaused
+	phaseEnded
+)
+
+// String returns a lower-case ASCII representation of the phase.
+func (p roundPhase) String() string {
+	switch p {
+	case phaseRunning:
+		return "running"
+	case phasePaused:
+		return "paused"
+	case phaseEnded:
+		return "ended"
+	default:
+		return "unknown"
+	}
+}
+
+var (
+	errRoundNotRunning = errors.New("round isn't running")
+	errRoundNotPaused  = errors.New("round isn't paused")
+	errRoundOver       = errors.New("round is already over")
+	errRoundUnlimited  = errors.New("round has no time limit")
+)
+
+// roundState controls the phase and the remaining time of the round. All
+// changes of the state are announced to the viewers.
+type roundState struct {
+	phase roundPhase
+
+	// end is the time the round ends while it's running. remaining is the
+	// time left while it's paused. Both are zero for rounds without limit
+	end       time.Time
+	remaining time.Duration
+	timer     *time.Timer
+
+	// generation is increased whenever the timer is stopped. A timer that
+	// already fired but waits for baton is outdated, if the generation
+	// changed in the meantime (e.g. the round has been paused)
+	generation int
+
+	// announce sends a chat message to all viewers
+	announce func(msg string, sev ntfydistr.Severity)
+
+	ended chan string
+	baton sync.Mutex
+}
+
+// round is the state of the current round. It's running without limit until
+// Start is called.
+var round = newRoundState()
+
+func newRoundState() *roundState {
+	return &roundState{
+		phase: phaseRunning,
+		announce: func(msg string, sev ntfydistr.Severity) {
+			dist.PushChatBroadcast(msg, sev, log)
+		},
+		ended: make(chan string, 1),
+	}
+}
+
+// Start starts the round's timer. A zero duration lets the round run until
+// it's ended explicitly.
+func (r *roundState) Start(d time.Duration) {
+	r.baton.Lock()
+	defer r.baton.Unlock()
+
+	if d > 0 {
+		r.startTimerUnsafe(d)
+	}
+}
+
+func (r *roundState) startTimerUnsafe(d time.Duration) {
+	r.end = time.Now().Add(d)
+	generation := r.generation
+	r.timer = time.AfterFunc(d, func() {
+		r.expire(generation)
+	})
+}
+
+// expire ends the round when the timer of the given generation fired.
+func (r *roundState) expire(generation int) {
+	r.baton.Lock()
+	defer r.baton.Unlock()
+
+	if generation != r.generation || r.phase != phaseRunning {
+		log.Debug("outdated round timer fired", zap.Stringer("phase", r.phase))
+		return
+	}
+	r.endUnsafe("The round is over")
+}
+
+// stopTimerUnsafe stops the timer and returns the time that was left.
+func (r *roundState) stopTimerUnsafe() time.Duration {
+	if r.timer == nil {
+		return 0
+	}
+	r.timer.Stop()
+	r.timer = nil
+	r.generation++
+
+	// the timer could have fired already and wait for baton. The round
+	// still has a limit and ends as soon as it's running again
+	left := time.Until(r.end)
+	if left <= 0 {
+		left = time.Nanosecond
+	}
+	r.end = time.Time{}
+	return left
+}
+
+// Phase returns the current phase of the round.
+func (r *roundState) Phase() roundPhase {
+	r.baton.Lock()
+	defer r.baton.Unlock()
+
+	return r.phase
+}
+
+// Remaining returns the time left till the round ends. The second return
+// value is false if the round has no time limit.
+func (r *roundState) Remaining() (time.Duration, bool) {
+	r.baton.Lock()
+	defer r.baton.Unlock()
+
+	switch {
+	case r.phase == phasePaused && r.remaining > 0:
+		return r.remaining, true
+	case r.phase == phaseRunning && r.timer != nil:
+		return time.Until(r.end), true
+	default:
+		return 0, false
+	}
+}
+
+// Pause freezes the round and it's timer.
+func (r *roundState) Pause() error {
+	r.baton.Lock()
+	defer r.baton.Unlock()
+
+	if r.phase != phaseRunning {
+		return errRoundNotRunning
+	}
+	r.phase = phasePaused
+	r.remaining = r.stopTimerUnsafe()
+
+	log.Info("round paused", zap.Duration("remaining", r.remaining))
+	r.announce("The round has been paused", ntfydistr.SeverityWarning)
+	return nil
+}
+
+// Resume continues a paused round.
+func (r *roundState) Resume() error {
+	r.baton.Lock()
+	defer r.baton.Unlock()
+
+	if r.phase != phasePaused {
+		return errRoundNotPaused
+	}
+	r.phase = phaseRunning
+	if r.remaining > 0 {
+		r.startTimerUnsafe(r.remaining)
+		r.remaining = 0
+	}
+
+	log.Info("round resumed")
+	r.announce("The round has been resumed", ntfydistr.SeveritySuccess)
+	return nil
+}
+
+// Extend adds d to the remaining time of the round.
+func (r *roundState) Extend(d time.Duration) error {
+	r.baton.Lock()
+	defer r.baton.Unlock()
+
+	switch {
+	case r.phase == phaseEnded:
+		return errRoundOver
+	case r.phase == phasePaused && r.remaining > 0:
+		r.remaining += d
+	case r.phase == phaseRunning && r.timer != nil:
+		r.startTimerUnsafe(r.stopTimerUnsafe() + d)
+	default:
+		return errRoundUnlimited
+	}
+
+	log.Info("round extended", zap.Duration("extension", d))
+	r.announce("The round has been extended by "+d.String(), ntfydistr.SeverityDefault)
+	return nil
+}
+
+// End finishes the round. The reason is passed to the receiver of Done.
+func (r *roundState) End(reason string) error {
+	r.baton.Lock()
+	defer r.baton.Unlock()
+
+	if r.phase == phaseEnded {
+		return errRoundOver
+	}
+	r.endUnsafe(reason)
+	return nil
+}
+
+func (r *roundState) endUnsafe(reason string) {
+	r.phase = phaseEnded
+	r.stopTimerUnsafe()
+	r.remaining = 0
+
+	log.Info("round ended", zap.String("reason", reason))
+	r.ended <- reason
+}
+
+// Done returns a channel that receives the reason as soon as the round ended.
+func (r *roundState) Done() <-chan string {
+	return r.ended
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vikebot/vbgs/pkg/ntfydistr"
+)
+
+// newTestRoundState returns a round that records it's announcements instead
+// of pushing them to the viewers.
+func newTestRoundState(announced *[]string) *roundState {
+	r := newRoundState()
+	r.announce = func(msg string, sev ntfydistr.Severity) {
+		*announced = append(*announced, msg)
+	}
+	return r
+}
+
+func TestRoundState(t *testing.T) {
+	// step changes the round and returns the error
+	type step func(r *roundState) error
+	pause := func(r *roundState) error { return r.Pause() }
+	resume := func(r *roundState) error { return r.Resume() }
+	extend := func(r *roundState) error { return r.Extend(time.Hour) }
+	end := func(r *roundState) error { return r.End("admin") }
+
+	var tests = []struct {
+		Name      string
+		Duration  time.Duration
+		Steps     []step
+		Errors    []error
+		Phase     roundPhase
+		Limited   bool
+		Remaining time.Duration
+		Announced int
+	}{
+		{"Test01: running -> paused", time.Hour,
+			[]step{pause}, []error{nil},
+			phasePaused, true, time.Hour, 1},
+		{"Test02: running -> paused -> running", time.Hour,
+			[]step{pause, resume}, []error{nil, nil},
+			phaseRunning, true, time.Hour, 2},
+		{"Test03: extending a paused round", time.Hour,
+			[]step{pause, extend}, []error{nil, nil},
+			phasePaused, true, 2 * time.Hour, 2},
+		{"Test04: extending a running round", time.Hour,
+			[]step{extend}, []error{nil},
+			phaseRunning, true, 2 * time.Hour, 1},
+		{"Test05: extending an unlimited round", 0,
+			[]step{extend}, []error{errRoundUnlimited},
+			phaseRunning, false, 0, 0},
+		{"Test06: extending a paused unlimited round", 0,
+			[]step{pause, extend}, []error{nil, errRoundUnlimited},
+			phasePaused, false, 0, 1},
+		{"Test07: double pause", time.Hour,
+			[]step{pause, pause}, []error{nil, errRoundNotRunning},
+			phasePaused, true, time.Hour, 1},
+		{"Test08: resuming a running round", time.Hour,
+			[]step{resume}, []error{errRoundNotPaused},
+			phaseRunning, true, time.Hour, 0},
+		{"Test09: double end", time.Hour,
+			[]step{end, end}, []error{nil, errRoundOver},
+			phaseEnded, false, 0, 0},
+		{"Test10: ended round can't be changed", time.Hour,
+			[]step{end, pause, resume, extend}, []error{nil, errRoundNotRunning, errRoundNotPaused, errRoundOver},
+			phaseEnded, false, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var announced []string
+			r := newTestRoundState(&announced)
+			r.Start(tt.Duration)
+			defer r.End("test finished")
+
+			for i, s := range tt.Steps {
+				assert.Equal(tt.Errors[i], s(r), "step %d", i)
+			}
+
+			assert.Equal(tt.Phase, r.Phase())
+			remaining, limited := r.Remaining()
+			assert.Equal(tt.Limited, limited)
+			assert.InDelta(float64(tt.Remaining), float64(remaining), float64(time.Second))
+			assert.Len(announced, tt.Announced)
+		})
+	}
+}
+
+func TestRoundStateDone(t *testing.T) {
+	var tests = []struct {
+		Name     string
+		Duration time.Duration
+		End      string
+		Reason   string
+	}{
+		{"Test01: explicit end", time.Hour, "The server is shutting down", "The server is shutting down"},
+		{"Test02: timer", 10 * time.Millisecond, "", "The round is over"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var announced []string
+			r := newTestRoundState(&announced)
+			r.Start(tt.Duration)
+			if tt.End != "" {
+				assert.NoError(r.End(tt.End))
+			}
+
+			select {
+			case reason := <-r.Done():
+				assert.Equal(tt.Reason, reason)
+			case <-time.After(time.Second):
+				assert.Fail("round didn't end")
+			}
+			assert.Equal(phaseEnded, r.Phase())
+		})
+	}
+}
+
+func TestRoundStateOutdatedTimer(t *testing.T) {
+	assert := assert.New(t)
+
+	var announced []string
+	r := newTestRoundState(&announced)
+	r.Start(time.Hour)
+
+	// the timer fires while the round is being paused. It must not end the
+	// paused round afterwards
+	r.baton.Lock()
+	generation := r.generation
+	r.baton.Unlock()
+
+	assert.NoError(r.Pause())
+	r.expire(generation)
+	assert.Equal(phasePaused, r.Phase())
+
+	// after resuming only the new timer ends the round
+	assert.NoError(r.Resume())
+	r.expire(generation)
+	assert.Equal(phaseRunning, r.Phase())
+
+	r.baton.Lock()
+	generation = r.generation
+	r.baton.Unlock()
+	r.expire(generation)
+	assert.Equal(phaseEnded, r.Phase())
+	assert.Equal("The round is over", <-r.Done())
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/vikebot/vbgs/pkg/ntfydistr"
+	"github.com/vikebot/vbgs/vbge"
+	"go.uber.org/zap"
+)
+
+func init() {
+	adminHandle(http.MethodGet, "rules", adminRules)
+	adminHandle(http.MethodPost, "rules/reload", adminRulesReload)
+}
+
+// readRules applies the rules file at path to the compiled in rules and
+// validates the result.
+func readRules(path string) (vbge.Rules, error) {
+	r := vbge.DefaultRules()
+
+	f, err := ioutil.ReadFile(path) /* #nosec G304 */
+	if err != nil {
+		return r, err
+	}
+	err = json.Unmarshal(f, &r)
+	if err != nil {
+		return r, err
+	}
+
+	if problems := r.Validate(); len(problems) > 0 {
+		return r, errors.New(strings.Join(problems, "; "))
+	}
+	return r, nil
+}
+
+// rulesInit loads the rules file configured in `battle.rules`. Without a
+// rules file the compiled in rules are used.
+func rulesInit() {
+	if config().Battle.Rules == "" {
+		log.Info("using default rules. no rules file configured")
+		return
+	}
+
+	r, err := readRules(config().Battle.Rules)
+	if err != nil {
+		log.Fatal("invalid rules file", zap.String("path", config().Battle.Rules), zap.Error(err))
+	}
+	err = vbge.SetRules(r)
+	if err != nil {
+		log.Fatal("failed to set rules", zap.Error(err))
+	}
+	log.Info("loaded rules", zap.String("path", config().Battle.Rules))
+}
+
+// rulesReload reads the rules file again and applies it. All changes are
+// announced to the players. If the file is invalid the current rules stay
+// in effect.
+func rulesReload() ([]string, error) {
+	if config().Battle.Rules == "" {
+		return nil, errors.New("no rules file configured")
+	}
+
+	r, err := readRules(config().Battle.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := rulesDiff(vbge.CurrentRules(), r)
+	err = vbge.SetRules(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return changes, nil
+	}
+
+	log.Info("reloaded rules", zap.Strings("changes", changes))
+	if dist != nil {
+		dist.PushChatBroadcast("The rules have changed: "+strings.Join(changes, ", "), ntfydistr.SeverityWarning, log)
+	}
+	return changes, nil
+}
+
+// rulesDiff describes every value that differs between old and new (e.g.
+// "damage 10 -> 20").
+func rulesDiff(old, new vbge.Rules) []string {
+	a, b := flattenRules(old), flattenRules(new)
+
+	changes := []string{}
+	for k, v := range b {
+		if a[k] != v {
+			changes = append(changes, fmt.Sprintf("%s %v -> %v", k, a[k], v))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+func flattenRules(r vbge.Rules) map[string]interface{} {
+	var m map[string]interface{}
+	buf, _ := json.Marshal(r)
+	_ = json.Unmarshal(buf, &m) /* #nosec G104 */
+
+	flat := map[string]interface{}{}
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			if sub, ok := v.(map[string]interface{}); ok {
+				walk(prefix+k+".", sub)
+				continue
+			}
+			flat[prefix+k] = v
+		}
+	}
+	walk("", m)
+	return flat
+}
+
+func adminRules(w http.ResponseWriter, r *http.Request) {
+	adminRespond(w, http.StatusOK, vbge.CurrentRules())
+}
+
+func adminRulesReload(w http.ResponseWriter, r *http.Request) {
+	changes, err := rulesReload()
+	if err != nil {
+		adminError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	adminRespond(w, http.StatusOK, struct {
+		Changes []string   `json:"changes"`
+		Rules   vbge.Rules `json:"rules"`
+	}{
+		changes,
+		vbge.CurrentRules(),
+	})
+}
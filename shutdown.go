@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vikebot/vbgs/pkg/ntfydistr"
+	"go.uber.org/zap"
+)
+
+var (
+	// shutdownReason contains the reason (string) told to bots and viewers
+	// once the shutdown started. It's unset while the round is running
+	shutdownReason atomic.Value
+
+	// services counts the network services and ntcp connections that haven't
+	// finished draining after the shutdown signal
+	services sync.WaitGroup
+
+	// distStop stops the update runners of the distributor
+	distStop chan struct{}
+)
+
+type shutdownPush struct {
+	Reason string `json:"reason"`
+}
+
+type roundResults struct {
+	RoundID int          `json:"round_id"`
+	Reason  string       `json:"reason"`
+	Ended   time.Time    `json:"ended"`
+	Stats   playersStats `json:"stats"`
+}
+
+// shuttingDown reports whether the shutdown already started and why.
+func shuttingDown() (reason string, ok bool) {
+	reason, ok = shutdownReason.Load().(string)
+	return
+}
+
+// shutdownContext returns the context network services use to drain their
+// connections. It expires after the configured shutdown timeout.
+func shutdownContext() (context.Context, context.CancelFunc) {
+	if config().Battle.ShutdownTimeout.Duration <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), config().Battle.ShutdownTimeout.Duration)
+}
+
+// shutdown ends the round. It stops accepting new connections, informs all
+// bots and viewers about the reason, persists the results, closes all
+// connections and flushes all outstanding notifications. shutdown returns as
+// soon as everything finished or the configured shutdown timeout exceeded.
+func shutdown(reason string, shutdownChan chan bool) {
+	ctx := log.With(zap.String("reason", reason))
+	ctx.Info("shutting down", zap.Duration("timeout", config().Battle.ShutdownTimeout.Duration))
+
+	shutdownReason.Store(reason)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		// stop accepting new bots and viewers
+		close(shutdownChan)
+
+		shutdownAnnounce(reason, ctx)
+		shutdownPersist(reason, ctx)
+
+		// close all bot connections. Each read loop cleans up after itself
+		for _, c := range ntcpRegistry.All() {
+			c.Kick(reason)
+		}
+
+		// send all remaining notifications and release the viewers
+		close(distStop)
+		dist.Close()
+
+		// wait till all listeners stopped and their connections drained. No
+		// op can write to the audit log afterwards
+		services.Wait()
+
+		if audit != nil {
+			audit.Close()
+		}
+	}()
+
+	if config().Battle.ShutdownTimeout.Duration <= 0 {
+		<-done
+		ctx.Info("shutdown completed")
+		return
+	}
+
+	select {
+	case <-done:
+		ctx.Info("shutdown completed")
+	case <-time.After(config().Battle.ShutdownTimeout.Duration):
+		ctx.Warn("shutdown timeout exceeded. exiting anyway")
+	}
+}
+
+// shutdownAnnounce tells all bots and viewers that the round is ending.
+func shutdownAnnounce(reason string, ctx *zap.Logger) {
+	for _, c := range ntcpRegistry.All() {
+		err := c.Push(pushPacket{
+			Type: "shutdown",
+			Push: true,
+			Obj: shutdownPush{
+				Reason: reason,
+			},
+		})
+		if err != nil {
+			c.Log.Debug("announcing shutdown failed", zap.Error(err))
+		}
+	}
+
+	dist.PushBroadcast("shutdown", shutdownPush{
+		Reason: reason,
+	}, ctx)
+	dist.PushChatBroadcast(reason, ntfydistr.SeverityWarning, ctx)
+}
+
+// shutdownPersist writes the final stats of all players into the results
+// directory (if configured).
+func shutdownPersist(reason string, ctx *zap.Logger) {
+	if config().Battle.ResultsDir == "" {
+		ctx.Info("persisting results disabled. no results directory configured")
+		return
+	}
+
+	stats, err := getPlayersStats()
+	if err != nil {
+		// usernames are unavailable, but the stats are still worth keeping
+		ctx.Warn("failed getting stats. persisting them without usernames", zap.Error(err))
+		stats = nil
+		for _, p := range battle.Players {
+			stats = append(stats, playerStats{
+				GRID:   p.GRenderID,
+				Kills:  p.Kills,
+				Deaths: p.Deaths,
+			})
+		}
+	}
+
+	buf, err := json.MarshalIndent(roundResults{
+		RoundID: config().Battle.RoundID,
+		Reason:  reason,
+		Ended:   time.Now().UTC(),
+		Stats:   stats,
+	}, "", "\t")
+	if err != nil {
+		ctx.Error("failed to marshal results", zap.Error(err))
+		return
+	}
+
+	err = os.MkdirAll(config().Battle.ResultsDir, 0755)
+	if err != nil {
+		ctx.Error("failed to create results directory", zap.Error(err))
+		return
+	}
+
+	filename := filepath.Join(config().Battle.ResultsDir, "round-"+strconv.Itoa(config().Battle.RoundID)+".json")
+	err = ioutil.WriteFile(filename, buf, 0644)
+	if err != nil {
+		ctx.Error("failed to write results", zap.String("filename", filename), zap.Error(err))
+		return
+	}
+	ctx.Info("persisted results", zap.String("filename", filename))
+}
@@ -19,15 +19,15 @@ func (h *Health) HealthSynced() int {
 // TakeDamage returns the health of the player after taking dmg
 func (h *Health) TakeDamage(p *Player) {
 	if p.IsDefending {
-		h.internalValue -= defaultDmg / 2
+		h.internalValue -= CurrentRules().Damage / 2
 	} else {
-		h.internalValue -= defaultDmg
+		h.internalValue -= CurrentRules().Damage
 	}
 }
 
 // NewDefaultHealth returns the default health
 func NewDefaultHealth() *Health {
-	return NewHealth(MaxHealth)
+	return NewHealth(CurrentRules().MaxHealth)
 }
 
 // NewHealth accepts an integer value that is returned as
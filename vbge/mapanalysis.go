@@ -0,0 +1,129 @@
+package vbge
+
+import "fmt"
+
+// MapAnalysis describes the connected regions of walkable blocks of a map.
+// Two blocks are connected if a player can move from one to the other
+// without entering InaccessableBlocks.
+type MapAnalysis struct {
+	Width  int
+	Height int
+	// Walkable is the number of accessable blocks
+	Walkable int
+	// Regions contains all connected regions. The largest (main) region is
+	// the first one
+	Regions [][]Location
+
+	// region contains the index of the region of each block or -1 if the
+	// block isn't accessable
+	region []int
+}
+
+// AnalyzeMap computes the walkable regions of blocks. blocks must be a valid
+// map (see ValidateMap).
+func AnalyzeMap(blocks [][]string) *MapAnalysis {
+	a := &MapAnalysis{}
+	if len(blocks) == 0 {
+		return a
+	}
+	a.Height, a.Width = len(blocks), len(blocks[0])
+	a.region = make([]int, a.Width*a.Height)
+	for i := range a.region {
+		a.region[i] = -1
+	}
+
+	for y := 0; y < a.Height; y++ {
+		for x := 0; x < a.Width; x++ {
+			if a.region[y*a.Width+x] >= 0 || !IsAccessableBlocktype(blocks[y][x]) {
+				continue
+			}
+
+			// breadth-first search through all connected blocks
+			idx := len(a.Regions)
+			a.region[y*a.Width+x] = idx
+			region := []Location{{X: x, Y: y}}
+			for i := 0; i < len(region); i++ {
+				for _, n := range neighbours(region[i].X, region[i].Y, a.Width, a.Height) {
+					if a.region[n.Y*a.Width+n.X] < 0 && IsAccessableBlocktype(blocks[n.Y][n.X]) {
+						a.region[n.Y*a.Width+n.X] = idx
+						region = append(region, n)
+					}
+				}
+			}
+
+			a.Walkable += len(region)
+			a.Regions = append(a.Regions, region)
+		}
+	}
+
+	largest := 0
+	for i, r := range a.Regions {
+		if len(r) > len(a.Regions[largest]) {
+			largest = i
+		}
+	}
+	if largest > 0 {
+		a.Regions[0], a.Regions[largest] = a.Regions[largest], a.Regions[0]
+		for i, r := range a.region {
+			switch r {
+			case 0:
+				a.region[i] = largest
+			case largest:
+				a.region[i] = 0
+			}
+		}
+	}
+	return a
+}
+
+// MainRegion returns the largest walkable region.
+func (a *MapAnalysis) MainRegion() []Location {
+	if len(a.Regions) == 0 {
+		return nil
+	}
+	return a.Regions[0]
+}
+
+// InMainRegion reports whether (x, y) belongs to the main region.
+func (a *MapAnalysis) InMainRegion(x, y int) bool {
+	if x < 0 || y < 0 || x >= a.Width || y >= a.Height {
+		return false
+	}
+	return a.region[y*a.Width+x] == 0
+}
+
+// Unreachable returns the number of walkable blocks outside the main region.
+// Players can't reach them from the main region.
+func (a *MapAnalysis) Unreachable() int {
+	return a.Walkable - len(a.MainRegion())
+}
+
+// Warnings describes the unreachable areas of the map.
+func (a *MapAnalysis) Warnings() (warnings []string) {
+	if a.Walkable == 0 {
+		return []string{"map has no walkable blocks"}
+	}
+	for _, r := range a.Regions[1:] {
+		warnings = append(warnings, fmt.Sprintf("%d walkable blocks around (%d,%d) are unreachable from the main region",
+			len(r), r[0].X, r[0].Y))
+	}
+	return warnings
+}
+
+// neighbours returns the locations next to (x, y) inside the map.
+func neighbours(x, y, width, height int) []Location {
+	n := make([]Location, 0, 4)
+	if y > 0 {
+		n = append(n, Location{X: x, Y: y - 1})
+	}
+	if x < width-1 {
+		n = append(n, Location{X: x + 1, Y: y})
+	}
+	if y < height-1 {
+		n = append(n, Location{X: x, Y: y + 1})
+	}
+	if x > 0 {
+		n = append(n, Location{X: x - 1, Y: y})
+	}
+	return n
+}
@@ -0,0 +1,54 @@
+package vbge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeMap(t *testing.T) {
+	assert := assert.New(t)
+
+	blocks := [][]string{
+		{blockGrass, blockWater, blockGrass, blockGrass},
+		{blockWater, blockWater, blockGrass, blockGrass},
+		{blockGrass, blockGrass, blockWater, blockGrass},
+	}
+	a := AnalyzeMap(blocks)
+
+	assert.Equal(8, a.Walkable)
+	assert.Len(a.Regions, 3)
+	assert.Len(a.MainRegion(), 5)
+	assert.Equal(3, a.Unreachable())
+
+	assert.True(a.InMainRegion(3, 2))
+	assert.False(a.InMainRegion(0, 0))
+	assert.False(a.InMainRegion(1, 0))
+	assert.False(a.InMainRegion(0, 2))
+	assert.False(a.InMainRegion(-1, 0))
+
+	assert.Equal([]string{
+		"1 walkable blocks around (0,0) are unreachable from the main region",
+		"2 walkable blocks around (0,2) are unreachable from the main region",
+	}, a.Warnings())
+}
+
+func TestAnalyzeMap_noWalkable(t *testing.T) {
+	assert := assert.New(t)
+
+	a := AnalyzeMap([][]string{{blockWater}})
+	assert.Empty(a.MainRegion())
+	assert.Equal(0, a.Unreachable())
+	assert.Equal([]string{"map has no walkable blocks"}, a.Warnings())
+}
+
+func TestAnalyzeMap_connected(t *testing.T) {
+	assert := assert.New(t)
+
+	a := AnalyzeMap([][]string{
+		{blockGrass, blockGrass},
+		{blockWater, blockGrass},
+	})
+	assert.Len(a.Regions, 1)
+	assert.Empty(a.Warnings())
+}
@@ -12,6 +12,15 @@ type MapEntity struct {
 	Width    int
 	Matrix   [][]*BlockEntity
 	SyncRoot sync.Mutex
+	// Analysis contains the walkable regions of the map. Players only spawn
+	// inside the main region. If it's nil the whole map is used
+	Analysis *MapAnalysis
+	// SpawnPoints and SpawnZones restrict where players spawn. Without them
+	// players spawn anywhere in the main region
+	SpawnPoints []Location
+	SpawnZones  []SpawnZone
+	// SpawnStrategy picks the spawn location. Defaults to RandomSpawn
+	SpawnStrategy SpawnStrategy
 }
 
 // NewMapEntity allocates memory for a new map with the size specified by the
@@ -53,11 +62,26 @@ func NewMapEntityFromMap(width, height int, blockMap [][]string) *MapEntity {
 		}
 	}
 
-	return &MapEntity{
+	me := &MapEntity{
 		Height: height,
 		Width:  width,
 		Matrix: matrix,
 	}
+	me.Analyze()
+	return me
+}
+
+// Analyze computes the walkable regions of the map. It must be called again
+// after blocktypes have been changed.
+func (me *MapEntity) Analyze() {
+	blocks := make([][]string, len(me.Matrix))
+	for y, row := range me.Matrix {
+		blocks[y] = make([]string, len(row))
+		for x, be := range row {
+			blocks[y][x] = be.Blocktype
+		}
+	}
+	me.Analysis = AnalyzeMap(blocks)
 }
 
 // PInEnclosedArea returns all players with their relative position to l inside
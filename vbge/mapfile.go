@@ -0,0 +1,76 @@
+package vbge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// MapFile is the content of a map file. A map file is either only the matrix
+// of blocktypes (rows from north to south) or an object that additionally
+// declares spawn points and zones:
+//
+//	{"blocks": [["grass", ...], ...], "spawns": [{"x": 1, "y": 2}], "zones": [{"team": "red", "x": 0, "y": 0, "width": 5, "height": 5}]}
+type MapFile struct {
+	Blocks [][]string  `json:"blocks"`
+	Spawns []Location  `json:"spawns,omitempty"`
+	Zones  []SpawnZone `json:"zones,omitempty"`
+}
+
+type mapFileObject MapFile
+
+// MarshalJSON writes maps without spawns as plain matrix, so they stay
+// readable by older versions.
+func (f MapFile) MarshalJSON() ([]byte, error) {
+	if len(f.Spawns) == 0 && len(f.Zones) == 0 {
+		return json.Marshal(f.Blocks)
+	}
+	return json.Marshal(mapFileObject(f))
+}
+
+// UnmarshalJSON reads both formats of map files.
+func (f *MapFile) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		*f = MapFile{}
+		return json.Unmarshal(data, &f.Blocks)
+	}
+	return json.Unmarshal(data, (*mapFileObject)(f))
+}
+
+// Validate returns a description of every problem of the map (see
+// ValidateMap) and its spawns. Spawn points and zones must be inside the
+// main region.
+func (f *MapFile) Validate() []string {
+	problems := ValidateMap(f.Blocks)
+	if len(problems) > 0 || (len(f.Spawns) == 0 && len(f.Zones) == 0) {
+		return problems
+	}
+
+	a := AnalyzeMap(f.Blocks)
+	for i, l := range f.Spawns {
+		if !a.InMainRegion(l.X, l.Y) {
+			problems = append(problems, fmt.Sprintf("spawn %d: (%d,%d) isn't a walkable block of the main region", i, l.X, l.Y))
+		}
+	}
+	for i, z := range f.Zones {
+		if z.Width <= 0 || z.Height <= 0 {
+			problems = append(problems, fmt.Sprintf("zone %d: width and height must be positive", i))
+			continue
+		}
+		if z.X < 0 || z.Y < 0 || z.X+z.Width > a.Width || z.Y+z.Height > a.Height {
+			problems = append(problems, fmt.Sprintf("zone %d: exceeds the map", i))
+			continue
+		}
+
+		usable := false
+		for y := z.Y; y < z.Y+z.Height && !usable; y++ {
+			for x := z.X; x < z.X+z.Width && !usable; x++ {
+				usable = a.InMainRegion(x, y)
+			}
+		}
+		if !usable {
+			problems = append(problems, fmt.Sprintf("zone %d: contains no walkable block of the main region", i))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,59 @@
+package vbge
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapFile_JSON(t *testing.T) {
+	assert := assert.New(t)
+
+	var plain MapFile
+	assert.NoError(json.Unmarshal([]byte(`[["grass","water"]]`), &plain))
+	assert.Equal(MapFile{Blocks: [][]string{{"grass", "water"}}}, plain)
+	buf, _ := json.Marshal(plain)
+	assert.JSONEq(`[["grass","water"]]`, string(buf))
+
+	var withSpawns MapFile
+	data := `{"blocks":[["grass","water"]],"spawns":[{"x":0,"y":0}],"zones":[{"team":"red","x":0,"y":0,"width":1,"height":1}]}`
+	assert.NoError(json.Unmarshal([]byte(data), &withSpawns))
+	assert.Equal([]Location{{X: 0, Y: 0}}, withSpawns.Spawns)
+	assert.Equal([]SpawnZone{{Team: "red", Width: 1, Height: 1}}, withSpawns.Zones)
+	buf, _ = json.Marshal(withSpawns)
+	assert.JSONEq(data, string(buf))
+}
+
+func TestMapFile_Validate(t *testing.T) {
+	assert := assert.New(t)
+
+	blocks := [][]string{
+		{blockGrass, blockWater, blockGrass},
+		{blockWater, blockGrass, blockGrass},
+	}
+
+	var tests = []struct {
+		Name     string
+		File     MapFile
+		Problems []string
+	}{
+		{"Test01: without spawns", MapFile{Blocks: blocks}, nil},
+		{"Test02: valid spawns", MapFile{Blocks: blocks, Spawns: []Location{{X: 2, Y: 0}}, Zones: []SpawnZone{{X: 1, Y: 1, Width: 2, Height: 1}}}, nil},
+		{"Test03: unreachable spawn", MapFile{Blocks: blocks, Spawns: []Location{{X: 0, Y: 0}, {X: 5, Y: 5}}}, []string{
+			"spawn 0: (0,0) isn't a walkable block of the main region",
+			"spawn 1: (5,5) isn't a walkable block of the main region",
+		}},
+		{"Test04: invalid zones", MapFile{Blocks: blocks, Zones: []SpawnZone{{Width: 0, Height: 1}, {X: 2, Width: 2, Height: 1}, {Width: 1, Height: 1}}}, []string{
+			"zone 0: width and height must be positive",
+			"zone 1: exceeds the map",
+			"zone 2: contains no walkable block of the main region",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(tt.Problems, tt.File.Validate())
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package vbge
+
+import (
+	"errors"
+	"math/rand"
+
+	"github.com/vikebot/vbcore"
+)
+
+// Symmetries of generated maps
+const (
+	SymmetryNone       = "none"
+	SymmetryMirror     = "mirror"
+	SymmetryRotational = "rotational"
+)
+
+// GenerateOptions configure GenerateMap. Zero values use the defaults.
+type GenerateOptions struct {
+	// Seed makes the generation reproducible. The same options always
+	// generate the same map
+	Seed   int64 `json:"seed"`
+	Width  int   `json:"width"`
+	Height int   `json:"height"`
+	// WaterRatio is the approximate share of water blocks (0 to 0.6)
+	WaterRatio float64 `json:"water_ratio"`
+	// LavaPockets is the number of lava pools surrounded by lavarock
+	LavaPockets int `json:"lava_pockets"`
+	// Biomes are the walkable ground blocktypes the map is split into
+	// (default grass and dirt_light)
+	Biomes []string `json:"biomes"`
+	// Symmetry is one of SymmetryNone (default), SymmetryMirror (left and
+	// right half) or SymmetryRotational (rotated by 180 degree around the
+	// center). Symmetric maps give all spawn sides the same terrain
+	Symmetry string `json:"symmetry"`
+}
+
+// maxWaterRatio limits the water, so enough walkable blocks are left
+const maxWaterRatio = 0.6
+
+// GenerateMap generates a map from the options. All walkable blocks of the
+// map are connected, so every location can be reached from every other one.
+func GenerateMap(opt GenerateOptions) ([][]string, error) {
+	err := opt.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if len(opt.Biomes) == 0 {
+		opt.Biomes = []string{blockGrass, blockLightDirt}
+	}
+	if opt.Symmetry == "" {
+		opt.Symmetry = SymmetryNone
+	}
+
+	/* #nosec G404 */
+	g := &mapGenerator{
+		GenerateOptions: opt,
+		rnd:             rand.New(rand.NewSource(opt.Seed)),
+	}
+	g.biomes()
+	g.water()
+	g.lava()
+	g.symmetrize()
+	g.connect()
+	return g.blocks, nil
+}
+
+// Validate returns an error if GenerateMap can't use the options.
+func (opt GenerateOptions) Validate() error {
+	if opt.Width <= 0 || opt.Height <= 0 {
+		return errors.New("vbge: map width and height must be positive")
+	}
+	if opt.WaterRatio < 0 || opt.WaterRatio > maxWaterRatio {
+		return errors.New("vbge: water ratio must be between 0 and 0.6")
+	}
+	if opt.LavaPockets < 0 {
+		return errors.New("vbge: lava pockets mustn't be negative")
+	}
+	for _, b := range opt.Biomes {
+		if !IsBlocktype(b) || !IsAccessableBlocktype(b) {
+			return errors.New("vbge: biome " + b + " isn't a walkable blocktype")
+		}
+	}
+	switch opt.Symmetry {
+	case "", SymmetryNone, SymmetryMirror, SymmetryRotational:
+	default:
+		return errors.New("vbge: unknown symmetry " + opt.Symmetry)
+	}
+
+	return nil
+}
+
+type mapGenerator struct {
+	GenerateOptions
+	rnd    *rand.Rand
+	blocks [][]string
+}
+
+// biomes splits the map into voronoi cells of the biomes.
+func (g *mapGenerator) biomes() {
+	type site struct {
+		x, y  int
+		biome string
+	}
+	sites := make([]site, len(g.Biomes)*3)
+	for i := range sites {
+		sites[i] = site{g.rnd.Intn(g.Width), g.rnd.Intn(g.Height), g.Biomes[i%len(g.Biomes)]}
+	}
+
+	g.blocks = make([][]string, g.Height)
+	for y := range g.blocks {
+		g.blocks[y] = make([]string, g.Width)
+		for x := range g.blocks[y] {
+			best, bestDist := 0, -1
+			for i, s := range sites {
+				d := (s.x-x)*(s.x-x) + (s.y-y)*(s.y-y)
+				if bestDist < 0 || d < bestDist {
+					best, bestDist = i, d
+				}
+			}
+			g.blocks[y][x] = sites[best].biome
+		}
+	}
+}
+
+// water adds lakes until the water ratio is reached.
+func (g *mapGenerator) water() {
+	target := int(g.WaterRatio * float64(g.Width*g.Height))
+	maxRadius := vbcore.MaxInt(2, vbcore.MinInt(g.Width, g.Height)/8)
+
+	count := 0
+	for i := 0; count < target && i < 10000; i++ {
+		cx, cy := g.rnd.Intn(g.Width), g.rnd.Intn(g.Height)
+		r := 1 + g.rnd.Intn(maxRadius)
+		g.disk(cx, cy, r, func(x, y int) {
+			if count < target && g.blocks[y][x] != blockWater {
+				g.blocks[y][x] = blockWater
+				count++
+			}
+		})
+	}
+}
+
+// lava adds pools of lava with a shore of lavarock.
+func (g *mapGenerator) lava() {
+	for i := 0; i < g.LavaPockets; i++ {
+		cx, cy := g.rnd.Intn(g.Width), g.rnd.Intn(g.Height)
+		r := 1 + g.rnd.Intn(2)
+		g.disk(cx, cy, r+1, func(x, y int) {
+			g.blocks[y][x] = blockLavarock
+		})
+		g.disk(cx, cy, r, func(x, y int) {
+			g.blocks[y][x] = blockLava
+		})
+	}
+}
+
+// disk calls fn for all blocks inside the map with a distance of at most r
+// to (cx, cy).
+func (g *mapGenerator) disk(cx, cy, r int, fn func(x, y int)) {
+	for y := vbcore.MaxInt(0, cy-r); y <= vbcore.MinInt(g.Height-1, cy+r); y++ {
+		for x := vbcore.MaxInt(0, cx-r); x <= vbcore.MinInt(g.Width-1, cx+r); x++ {
+			if (x-cx)*(x-cx)+(y-cy)*(y-cy) <= r*r {
+				fn(x, y)
+			}
+		}
+	}
+}
+
+// counterpart returns the block (x, y) is copied from. Blocks that aren't
+// copied are their own counterpart.
+func (g *mapGenerator) counterpart(x, y int) (int, int) {
+	switch g.Symmetry {
+	case SymmetryMirror:
+		if x >= (g.Width+1)/2 {
+			return g.Width - 1 - x, y
+		}
+	case SymmetryRotational:
+		if y*g.Width+x >= (g.Width*g.Height+1)/2 {
+			return g.Width - 1 - x, g.Height - 1 - y
+		}
+	}
+	return x, y
+}
+
+// symmetrize copies the first half of the map onto the second one.
+func (g *mapGenerator) symmetrize() {
+	for y := range g.blocks {
+		for x := range g.blocks[y] {
+			cx, cy := g.counterpart(x, y)
+			g.blocks[y][x] = g.blocks[cy][cx]
+		}
+	}
+}
+
+// set changes a block and keeps the map symmetric.
+func (g *mapGenerator) set(x, y int, blocktype string) {
+	g.blocks[y][x] = blocktype
+	switch g.Symmetry {
+	case SymmetryMirror:
+		g.blocks[y][g.Width-1-x] = blocktype
+	case SymmetryRotational:
+		g.blocks[g.Height-1-y][g.Width-1-x] = blocktype
+	}
+}
+
+// connect builds bridges from every walkable region to the largest one,
+// until only a single region is left.
+func (g *mapGenerator) connect() {
+	for {
+		a := AnalyzeMap(g.blocks)
+		if len(a.Regions) <= 1 {
+			return
+		}
+		g.bridge(a.Regions[1], a)
+	}
+}
+
+// bridge turns the inaccessable blocks of the shortest path from region to
+// the main region into ground.
+func (g *mapGenerator) bridge(region []Location, a *MapAnalysis) {
+	prev := make([]int, g.Width*g.Height)
+	for i := range prev {
+		prev[i] = -1
+	}
+
+	queue := make([]int, 0, len(region))
+	for _, l := range region {
+		i := l.Y*g.Width + l.X
+		prev[i] = i
+		queue = append(queue, i)
+	}
+
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		if a.InMainRegion(i%g.Width, i/g.Width) {
+			for ; prev[i] != i; i = prev[i] {
+				x, y := i%g.Width, i/g.Width
+				if !IsAccessableBlocktype(g.blocks[y][x]) {
+					g.set(x, y, g.Biomes[0])
+				}
+			}
+			return
+		}
+
+		for _, n := range neighbours(i%g.Width, i/g.Width, g.Width, g.Height) {
+			j := n.Y*g.Width + n.X
+			if prev[j] < 0 {
+				prev[j] = i
+				queue = append(queue, j)
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package vbge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateMap(t *testing.T) {
+	assert := assert.New(t)
+
+	var tests = []struct {
+		Name string
+		Opt  GenerateOptions
+	}{
+		{"Test01: defaults", GenerateOptions{Seed: 1, Width: 20, Height: 10}},
+		{"Test02: lots of water", GenerateOptions{Seed: 2, Width: 40, Height: 40, WaterRatio: 0.6}},
+		{"Test03: mirrored", GenerateOptions{Seed: 3, Width: 31, Height: 31, WaterRatio: 0.4, LavaPockets: 3, Symmetry: SymmetryMirror}},
+		{"Test04: rotational", GenerateOptions{Seed: 4, Width: 30, Height: 21, WaterRatio: 0.5, Biomes: []string{blockSwamp, blockDirt, blockGrass}, Symmetry: SymmetryRotational}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			blocks, err := GenerateMap(tt.Opt)
+			assert.NoError(err)
+			assert.Nil(ValidateMap(blocks))
+			assert.Len(blocks, tt.Opt.Height)
+			assert.Len(blocks[0], tt.Opt.Width)
+			assert.Len(AnalyzeMap(blocks).Regions, 1)
+
+			again, _ := GenerateMap(tt.Opt)
+			assert.Equal(blocks, again)
+
+			for y := range blocks {
+				for x := range blocks[y] {
+					switch tt.Opt.Symmetry {
+					case SymmetryMirror:
+						assert.Equal(blocks[y][x], blocks[y][tt.Opt.Width-1-x])
+					case SymmetryRotational:
+						assert.Equal(blocks[y][x], blocks[tt.Opt.Height-1-y][tt.Opt.Width-1-x])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateMap_water(t *testing.T) {
+	assert := assert.New(t)
+
+	blocks, err := GenerateMap(GenerateOptions{Seed: 5, Width: 50, Height: 50, WaterRatio: 0.3})
+	assert.NoError(err)
+
+	water := 0
+	for _, row := range blocks {
+		for _, b := range row {
+			if b == blockWater {
+				water++
+			}
+		}
+	}
+	// bridges only remove a few water blocks
+	assert.InDelta(0.3, float64(water)/2500, 0.05)
+}
+
+func TestGenerateMap_invalid(t *testing.T) {
+	assert := assert.New(t)
+
+	var tests = []struct {
+		Name string
+		Opt  GenerateOptions
+	}{
+		{"Test01: no size", GenerateOptions{}},
+		{"Test02: too much water", GenerateOptions{Width: 5, Height: 5, WaterRatio: 0.9}},
+		{"Test03: water biome", GenerateOptions{Width: 5, Height: 5, Biomes: []string{blockWater}}},
+		{"Test04: unknown symmetry", GenerateOptions{Width: 5, Height: 5, Symmetry: "diagonal"}},
+		{"Test05: negative lava", GenerateOptions{Width: 5, Height: 5, LavaPockets: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			_, err := GenerateMap(tt.Opt)
+			assert.Error(err)
+		})
+	}
+}
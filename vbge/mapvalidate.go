@@ -0,0 +1,25 @@
+package vbge
+
+import "fmt"
+
+// ValidateMap checks whether blocks can be used as map and returns a
+// description of every problem. A valid map is a non-empty rectangle of
+// known blocktypes.
+func ValidateMap(blocks [][]string) (problems []string) {
+	if len(blocks) == 0 || len(blocks[0]) == 0 {
+		return []string{"map is empty"}
+	}
+
+	width := len(blocks[0])
+	for y, row := range blocks {
+		if len(row) != width {
+			problems = append(problems, fmt.Sprintf("row %d: has %d blocks instead of %d", y, len(row), width))
+		}
+		for x, b := range row {
+			if !IsBlocktype(b) {
+				problems = append(problems, fmt.Sprintf("block (%d,%d): unknown blocktype %q", x, y, b))
+			}
+		}
+	}
+	return problems
+}
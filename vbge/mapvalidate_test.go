@@ -0,0 +1,28 @@
+package vbge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateMap(t *testing.T) {
+	assert := assert.New(t)
+
+	var tests = []struct {
+		Name     string
+		Blocks   [][]string
+		Problems []string
+	}{
+		{"Test01: valid map", [][]string{{blockGrass, blockWater}, {blockTree, blockDirt}}, nil},
+		{"Test02: empty map", [][]string{}, []string{"map is empty"}},
+		{"Test03: ragged rows", [][]string{{blockGrass, blockGrass}, {blockGrass}}, []string{"row 1: has 1 blocks instead of 2"}},
+		{"Test04: unknown blocktype", [][]string{{blockGrass, "bridge"}}, []string{`block (1,0): unknown blocktype "bridge"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(tt.Problems, ValidateMap(tt.Blocks))
+		})
+	}
+}
@@ -17,17 +17,17 @@ type OpLimitations struct {
 }
 
 // NewOpLimitations returns a new pointer to a new OpLimitation container
-// with default time-limitations already set.
+// whose time-limitations follow the current rules.
 func NewOpLimitations() *OpLimitations {
 	return &OpLimitations{
-		Rotate:      ratelimit.New(rotateThrottle),
-		Move:        ratelimit.New(moveThrottle),
-		Radar:       ratelimit.New(radarThrottle),
-		Scout:       ratelimit.New(scoutThrottle),
-		Environment: ratelimit.New(environmentThrottle),
-		Watch:       ratelimit.New(watchThrottle),
-		Attack:      ratelimit.New(attackThrottle),
-		Defend:      ratelimit.New(defendThrottle),
-		Health:      ratelimit.New(healthThrottle),
+		Rotate:      newThrottle(func(t Throttles) int { return t.Rotate }),
+		Move:        newThrottle(func(t Throttles) int { return t.Move }),
+		Radar:       newThrottle(func(t Throttles) int { return t.Radar }),
+		Scout:       newThrottle(func(t Throttles) int { return t.Scout }),
+		Environment: newThrottle(func(t Throttles) int { return t.Environment }),
+		Watch:       newThrottle(func(t Throttles) int { return t.Watch }),
+		Attack:      newThrottle(func(t Throttles) int { return t.Attack }),
+		Defend:      newThrottle(func(t Throttles) int { return t.Defend }),
+		Health:      newThrottle(func(t Throttles) int { return t.Health }),
 	}
 }
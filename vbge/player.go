@@ -1,7 +1,6 @@
 package vbge
 
 import (
-	"errors"
 	"math/rand"
 	"strconv"
 
@@ -151,6 +150,7 @@ func (p *Player) Move(dir string) (ngl NotifyGroupLocated, err error) {
 // Radar implements https://sdk-wiki.vikebot.com/#radar
 func (p *Player) Radar() (playerCount int, ngl NotifyGroupLocated) {
 	// calculate enclosing
+	radarRadius := CurrentRules().RadarRadius
 	startX := vbcore.MaxInt(0, p.Location.X-radarRadius)
 	endX := vbcore.MinInt(MapWidth, p.Location.X+radarRadius)
 	startY := vbcore.MaxInt(0, p.Location.Y-radarRadius)
@@ -424,34 +424,21 @@ func (p *Player) Undefend() (ngl NotifyGroupLocated, err error) {
 	return p.Map.PInRenderArea(p.Location), nil
 }
 
-// Spawn places the player randomly on the map as long as the location doesn't
-// already have a resident. If so Spawn will retry 100 times. If no suitable
-// location is found an error is returned.
+// Spawn places the player on a free location picked by the map's
+// SpawnStrategy. If the strategy doesn't find a location the first free one
+// is used. Only if the whole map is occupied an error is returned.
 func (p *Player) Spawn() error {
-	for i := 0; i < 100; i++ {
-		// Randomly generate a position inside the map
-		/* #nosec G404 */
-		loc := Location{
-			X: rand.Int() % MapWidth,
-			Y: rand.Int() % MapHeight,
-		}
-
-		// Check whether there already is a player or not
-		empty := !p.Map.Matrix[loc.Y][loc.X].HasResident()
-		isWater := p.Map.Matrix[loc.Y][loc.X].Blocktype == blockWater
-
-		if empty && !isWater {
-			// If the field is empty and not water we place the player
-			p.Map.Matrix[loc.Y][loc.X].JoinArea(p)
-			p.Location = &loc
-			p.Health = NewDefaultHealth()
-			p.WatchDir = dirNorth
-			p.IsDefending = false
-			return nil
-		}
+	loc, err := p.Map.spawnLocation(p)
+	if err != nil {
+		return err
 	}
 
-	return errors.New("vbge: unable to find a suitable location to place the player during spawn")
+	p.Map.Matrix[loc.Y][loc.X].JoinArea(p)
+	p.Location = &loc
+	p.Health = NewDefaultHealth()
+	p.WatchDir = dirNorth
+	p.IsDefending = false
+	return nil
 }
 
 // SpawnSynced is like `Spawn` but locks the Map
@@ -479,6 +466,42 @@ func (p *Player) Respawn() error {
 	return nil
 }
 
+// Relocate removes the player from it's current location and places it at loc
+// or, if loc is nil, at a random location (see `Spawn`). All players around
+// the old location are informed through onLeave and all players around the
+// new location through onSpawn. Relocate locks the Map.
+func (p *Player) Relocate(loc *Location, onLeave DeathEvent, onSpawn SpawnEvent) error {
+	p.Map.SyncRoot.Lock()
+	defer p.Map.SyncRoot.Unlock()
+
+	if loc != nil {
+		if !loc.IsInMap() {
+			return ErrOutOfMap
+		}
+		if !loc.IsAccessable(p.Map) {
+			return ErrInaccessable
+		}
+		if p.Map.Matrix[loc.Y][loc.X].HasResident() {
+			return ErrHasResident
+		}
+	}
+
+	onLeave(p, p.Map.PInRenderArea(p.Location))
+
+	if loc == nil {
+		err := p.Respawn()
+		if err != nil {
+			return err
+		}
+	} else {
+		p.Map.Matrix[p.Location.Y][p.Location.X].LeaveArea()
+		p.Map.Matrix[loc.Y][loc.X].JoinArea(p)
+		p.Location = loc.DeepCopy()
+	}
+
+	return onSpawn(p, p.Map.PInRenderArea(p.Location))
+}
+
 // RespawnSynced is like `Respawn` but locks the Map
 func (p *Player) RespawnSynced() error {
 	p.Map.SyncRoot.Lock()
@@ -574,3 +574,83 @@ func newPlayer(health, kills, deaths, y, x int, isDefending bool) *Player {
 		Rl:          NewOpLimitations(),
 	}
 }
+
+func TestPlayerRelocate(t *testing.T) {
+	cases := []struct {
+		name         string
+		to           *Location
+		blocktype    string
+		resident     bool
+		wantedError  error
+		wantedEvents int
+	}{
+		{"Teleport", newLocation(1, 1), blockLightDirt, false, nil, 2},
+		{"Out of map", newLocation(-1, 1), blockLightDirt, false, ErrOutOfMap, 0},
+		{"Inaccessable", newLocation(1, 1), blockWater, false, ErrInaccessable, 0},
+		{"Has resident", newLocation(1, 1), blockLightDirt, true, ErrHasResident, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			from := newLocation(HalfmapHeight, HalfmapWidth)
+			p := Player{
+				UserID:   1,
+				Map:      NewMapEntity(MapWidth, MapHeight),
+				Location: from,
+				Health:   NewDefaultHealth(),
+				Rl:       NewOpLimitations(),
+			}
+			p.Map.Matrix[from.Y][from.X].JoinArea(&p)
+			if c.to.IsInMap() {
+				p.Map.Matrix[c.to.Y][c.to.X].Blocktype = c.blocktype
+				if c.resident {
+					p.Map.Matrix[c.to.Y][c.to.X].JoinArea(&Player{UserID: 2, Location: c.to})
+				}
+			}
+
+			events := 0
+			err := p.Relocate(c.to, func(e *Player, ngl NotifyGroupLocated) {
+				events++
+			}, func(e *Player, ngl NotifyGroupLocated) error {
+				events++
+				return nil
+			})
+			if err != c.wantedError {
+				t.Fatalf("Relocate() = %v, want %v", err, c.wantedError)
+			}
+			if events != c.wantedEvents {
+				t.Errorf("events = %d, want %d", events, c.wantedEvents)
+			}
+			if err != nil {
+				return
+			}
+
+			if p.Location.X != c.to.X || p.Location.Y != c.to.Y {
+				t.Errorf("p.Location = %v, want %v", p.Location, c.to)
+			}
+			if p.Map.Matrix[from.Y][from.X].HasResident() {
+				t.Errorf("old location still has a resident")
+			}
+			if p.Map.Matrix[c.to.Y][c.to.X].Resident != &p {
+				t.Errorf("new location's resident isn't the player")
+			}
+		})
+	}
+}
+
+func TestPlayerSpawn_mainRegion(t *testing.T) {
+	m := NewMapEntityFromMap(5, 1, [][]string{{blockGrass, blockWater, blockGrass, blockGrass, blockGrass}})
+	p := &Player{UserID: 1, Map: m}
+
+	for i := 0; i < 50; i++ {
+		if p.Location != nil {
+			m.Matrix[p.Location.Y][p.Location.X].LeaveArea()
+		}
+		if err := p.Spawn(); err != nil {
+			t.Fatalf("Spawn() error = %v", err)
+		}
+		if !m.Analysis.InMainRegion(p.Location.X, p.Location.Y) {
+			t.Errorf("Spawn() = %v, outside of the main region", *p.Location)
+		}
+	}
+}
@@ -28,6 +28,9 @@ const (
 	blockWater     = "water"
 	blockEndOfMap  = "endofmap"
 	blockFog       = "fog"
+	blockMountain  = "mountain"
+	blockMountainL = "mountain_light"
+	blockTree      = "tree"
 
 	humanArmoredArcherMale = "male_armored_archer"
 	humanKnightMale        = "male_night"
@@ -82,6 +85,17 @@ var InaccessableBlocks = [...]string{
 	blockWater,
 }
 
+// IsAccessableBlocktype determines whether a player can enter blocks of the
+// `blocktype`
+func IsAccessableBlocktype(blocktype string) bool {
+	for _, b := range InaccessableBlocks {
+		if blocktype == b {
+			return false
+		}
+	}
+	return true
+}
+
 // SetMapDimensions sets the default map dimensions (e.g. width and health)
 func SetMapDimensions(width, height int) {
 	MapWidth = width
@@ -109,7 +123,7 @@ func IsDir(dirCandidate string) bool {
 // IsBlocktype determines whether the `blocktypeCandidate` is actually a valid blocktype
 func IsBlocktype(blocktypeCandidate string) bool {
 	btc := blocktypeCandidate
-	if btc == blockSwamp || btc == blockStonetile || btc == blockDirt || btc == blockGrass || btc == blockLava || btc == blockLavarock || btc == blockWater || btc == blockEndOfMap || btc == blockFog {
+	if btc == blockSwamp || btc == blockStonetile || btc == blockDirt || btc == blockGrass || btc == blockLava || btc == blockLavarock || btc == blockWater || btc == blockEndOfMap || btc == blockFog || btc == blockLightDirt || btc == blockMountain || btc == blockMountainL || btc == blockTree {
 		return true
 	}
 	return false
@@ -117,5 +131,5 @@ func IsBlocktype(blocktypeCandidate string) bool {
 
 // IsDistance determines wether the `distanceCanidate` is actually a valid distance
 func IsDistance(distanceCandidate int) bool {
-	return distanceCandidate > 0 && distanceCandidate < maxScoutLength
+	return distanceCandidate > 0 && distanceCandidate < CurrentRules().MaxScoutLength
 }
@@ -95,6 +95,10 @@ func TestIsBlocktype(t *testing.T) {
 		{"water", blockWater, true},
 		{"endofmap", blockEndOfMap, true},
 		{"fog", blockFog, true},
+		{"dirt_light", blockLightDirt, true},
+		{"mountain", blockMountain, true},
+		{"mountain_light", blockMountainL, true},
+		{"tree", blockTree, true},
 
 		{"Empty", "", false},
 		{"Random 1", vbcore.FastRandomString(4), false},
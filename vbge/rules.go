@@ -0,0 +1,132 @@
+package vbge
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/ratelimit"
+)
+
+// Rules contains the tuning values of the game. They default to the compiled
+// in values and can be replaced during a running round through SetRules.
+type Rules struct {
+	MaxHealth      int       `json:"max_health"`
+	Damage         int       `json:"damage"`
+	RadarRadius    int       `json:"radar_radius"`
+	MaxScoutLength int       `json:"max_scout_length"`
+	Throttles      Throttles `json:"throttles"`
+}
+
+// Throttles describe how often each operation can be called PER SECOND from
+// a client (see ratelimits.go).
+type Throttles struct {
+	Rotate      int `json:"rotate"`
+	Move        int `json:"move"`
+	Attack      int `json:"attack"`
+	Radar       int `json:"radar"`
+	Watch       int `json:"watch"`
+	Environment int `json:"environment"`
+	Scout       int `json:"scout"`
+	Defend      int `json:"defend"`
+	Health      int `json:"health"`
+}
+
+// DefaultRules returns the compiled in rules.
+func DefaultRules() Rules {
+	return Rules{
+		MaxHealth:      MaxHealth,
+		Damage:         defaultDmg,
+		RadarRadius:    radarRadius,
+		MaxScoutLength: maxScoutLength,
+		Throttles: Throttles{
+			Rotate:      rotateThrottle,
+			Move:        moveThrottle,
+			Attack:      attackThrottle,
+			Radar:       radarThrottle,
+			Watch:       watchThrottle,
+			Environment: environmentThrottle,
+			Scout:       scoutThrottle,
+			Defend:      defendThrottle,
+			Health:      healthThrottle,
+		},
+	}
+}
+
+// Validate returns a description of every invalid value.
+func (r Rules) Validate() (problems []string) {
+	positive := func(name string, v int) {
+		if v <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: must be positive", name))
+		}
+	}
+
+	positive("max_health", r.MaxHealth)
+	positive("damage", r.Damage)
+	positive("radar_radius", r.RadarRadius)
+	positive("max_scout_length", r.MaxScoutLength)
+	positive("throttles.rotate", r.Throttles.Rotate)
+	positive("throttles.move", r.Throttles.Move)
+	positive("throttles.attack", r.Throttles.Attack)
+	positive("throttles.radar", r.Throttles.Radar)
+	positive("throttles.watch", r.Throttles.Watch)
+	positive("throttles.environment", r.Throttles.Environment)
+	positive("throttles.scout", r.Throttles.Scout)
+	positive("throttles.defend", r.Throttles.Defend)
+	positive("throttles.health", r.Throttles.Health)
+
+	if r.Damage > r.MaxHealth {
+		problems = append(problems, "damage: mustn't be greater than max_health")
+	}
+	return problems
+}
+
+var rules atomic.Value
+
+func init() {
+	rules.Store(DefaultRules())
+}
+
+// CurrentRules returns the rules currently in effect.
+func CurrentRules() Rules {
+	return rules.Load().(Rules)
+}
+
+// SetRules replaces the current rules if r is valid. Changed throttles are
+// applied to the next operation of each player.
+func SetRules(r Rules) error {
+	if problems := r.Validate(); len(problems) > 0 {
+		return fmt.Errorf("invalid rules: %v", problems)
+	}
+	rules.Store(r)
+	return nil
+}
+
+// throttle is a ratelimit.Limiter whose rate follows the current rules.
+type throttle struct {
+	rate    func(t Throttles) int
+	current int
+	limiter ratelimit.Limiter
+	baton   sync.Mutex
+}
+
+func newThrottle(rate func(t Throttles) int) *throttle {
+	return &throttle{
+		rate: rate,
+	}
+}
+
+// Take blocks until the operation may be executed.
+func (t *throttle) Take() time.Time {
+	t.baton.Lock()
+	rate := t.rate(CurrentRules().Throttles)
+	if t.limiter == nil || rate != t.current {
+		t.limiter = ratelimit.New(rate)
+		t.current = rate
+	}
+	l := t.limiter
+	t.baton.Unlock()
+
+	return l.Take()
+}
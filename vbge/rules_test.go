@@ -0,0 +1,70 @@
+package vbge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRulesValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	invalid := DefaultRules()
+	invalid.Damage = 0
+	invalid.Throttles.Move = -1
+
+	tooStrong := DefaultRules()
+	tooStrong.Damage = tooStrong.MaxHealth + 1
+
+	var tests = []struct {
+		Name     string
+		Rules    Rules
+		Problems []string
+	}{
+		{"Test01: defaults are valid", DefaultRules(), nil},
+		{"Test02: non positive values", invalid, []string{"damage: must be positive", "throttles.move: must be positive"}},
+		{"Test03: damage greater than health", tooStrong, []string{"damage: mustn't be greater than max_health"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(tt.Problems, tt.Rules.Validate())
+		})
+	}
+}
+
+func TestSetRules(t *testing.T) {
+	assert := assert.New(t)
+	defer SetRules(DefaultRules())
+
+	invalid := DefaultRules()
+	invalid.MaxHealth = 0
+	assert.Error(SetRules(invalid))
+	assert.Equal(DefaultRules(), CurrentRules())
+
+	changed := DefaultRules()
+	changed.MaxHealth = 200
+	changed.Damage = 50
+	assert.NoError(SetRules(changed))
+	assert.Equal(200, NewDefaultHealth().HealthSynced())
+
+	p := &Player{}
+	h := NewDefaultHealth()
+	h.TakeDamage(p)
+	assert.Equal(150, h.HealthSynced())
+}
+
+func TestThrottleFollowsRules(t *testing.T) {
+	assert := assert.New(t)
+	defer SetRules(DefaultRules())
+
+	th := newThrottle(func(t Throttles) int { return t.Move })
+	th.Take()
+	assert.Equal(moveThrottle, th.current)
+
+	changed := DefaultRules()
+	changed.Throttles.Move = 5
+	assert.NoError(SetRules(changed))
+	th.Take()
+	assert.Equal(5, th.current)
+}
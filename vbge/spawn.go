@@ -0,0 +1,246 @@
+package vbge
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+)
+
+// SpawnZone is a rectangular area of the map players spawn in. Zones with a
+// team are reserved for the players of that team (see TeamZoneSpawn).
+type SpawnZone struct {
+	Team   string `json:"team,omitempty"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Contains reports whether l is inside the zone.
+func (z SpawnZone) Contains(l Location) bool {
+	return l.X >= z.X && l.X < z.X+z.Width && l.Y >= z.Y && l.Y < z.Y+z.Height
+}
+
+// SpawnCandidate is a free location a player can spawn at.
+type SpawnCandidate struct {
+	Location
+	// Team of the zone the candidate belongs to. Empty for spawn points, the
+	// main region and zones without team
+	Team string
+}
+
+// SpawnStrategy picks the location a player spawns at. All candidates are
+// free and accessable. If the strategy doesn't find a location (ok is false)
+// the map is scanned for the first free location instead.
+type SpawnStrategy interface {
+	Pick(p *Player, m *MapEntity, candidates []SpawnCandidate) (l Location, ok bool)
+}
+
+// RandomSpawn picks a random candidate.
+type RandomSpawn struct{}
+
+// Pick implements SpawnStrategy
+func (RandomSpawn) Pick(p *Player, m *MapEntity, candidates []SpawnCandidate) (Location, bool) {
+	if len(candidates) == 0 {
+		return Location{}, false
+	}
+	return candidates[rand.Intn(len(candidates))].Location, true /* #nosec G404 */
+}
+
+// FarthestSpawn picks the candidate with the largest distance to the nearest
+// other player, so nobody spawns right next to an enemy.
+type FarthestSpawn struct{}
+
+// Pick implements SpawnStrategy
+func (FarthestSpawn) Pick(p *Player, m *MapEntity, candidates []SpawnCandidate) (Location, bool) {
+	if len(candidates) == 0 {
+		return Location{}, false
+	}
+
+	var enemies []Location
+	for y, row := range m.Matrix {
+		for x, be := range row {
+			if be.HasResident() && be.Resident != p {
+				enemies = append(enemies, Location{X: x, Y: y})
+			}
+		}
+	}
+	if len(enemies) == 0 {
+		return RandomSpawn{}.Pick(p, m, candidates)
+	}
+
+	best, bestDist := candidates[0].Location, -1
+	for _, c := range candidates {
+		nearest := -1
+		for _, e := range enemies {
+			d := (c.X-e.X)*(c.X-e.X) + (c.Y-e.Y)*(c.Y-e.Y)
+			if nearest < 0 || d < nearest {
+				nearest = d
+			}
+		}
+		if nearest > bestDist {
+			best, bestDist = c.Location, nearest
+		}
+	}
+	return best, true
+}
+
+// TeamZoneSpawn picks a random candidate of the zones of the player's team.
+// Players without team (or teams without zones) spawn in the zones without
+// team and otherwise anywhere.
+type TeamZoneSpawn struct {
+	// Teams maps user ids to their team
+	Teams map[int]string
+}
+
+// Pick implements SpawnStrategy
+func (s TeamZoneSpawn) Pick(p *Player, m *MapEntity, candidates []SpawnCandidate) (Location, bool) {
+	team := s.Teams[p.UserID]
+
+	var own, neutral []SpawnCandidate
+	for _, c := range candidates {
+		switch {
+		case c.Team == "":
+			neutral = append(neutral, c)
+		case c.Team == team:
+			own = append(own, c)
+		}
+	}
+
+	if len(own) > 0 {
+		return RandomSpawn{}.Pick(p, m, own)
+	}
+	if len(neutral) > 0 {
+		return RandomSpawn{}.Pick(p, m, neutral)
+	}
+	return RandomSpawn{}.Pick(p, m, candidates)
+}
+
+// RoundRobinSpawn uses the spawn points of the map one after another.
+// Occupied points are skipped. If all points are occupied (or the map has
+// none) a random candidate is picked.
+type RoundRobinSpawn struct {
+	next  int
+	baton sync.Mutex
+}
+
+// Pick implements SpawnStrategy
+func (s *RoundRobinSpawn) Pick(p *Player, m *MapEntity, candidates []SpawnCandidate) (Location, bool) {
+	if len(m.SpawnPoints) == 0 {
+		return RandomSpawn{}.Pick(p, m, candidates)
+	}
+
+	free := make(map[Location]bool, len(candidates))
+	for _, c := range candidates {
+		free[c.Location] = true
+	}
+
+	s.baton.Lock()
+	defer s.baton.Unlock()
+
+	for i := 0; i < len(m.SpawnPoints); i++ {
+		l := m.SpawnPoints[(s.next+i)%len(m.SpawnPoints)]
+		if free[l] {
+			s.next = (s.next + i + 1) % len(m.SpawnPoints)
+			return l, true
+		}
+	}
+	return RandomSpawn{}.Pick(p, m, candidates)
+}
+
+// NewSpawnStrategy returns the strategy with the name random, farthest,
+// team or round_robin. teams is only used by the team strategy.
+func NewSpawnStrategy(name string, teams map[int]string) (SpawnStrategy, error) {
+	switch name {
+	case "", "random":
+		return RandomSpawn{}, nil
+	case "farthest":
+		return FarthestSpawn{}, nil
+	case "team":
+		return TeamZoneSpawn{Teams: teams}, nil
+	case "round_robin":
+		return &RoundRobinSpawn{}, nil
+	}
+	return nil, errors.New("vbge: unknown spawn strategy " + name)
+}
+
+// spawnable reports whether a player can spawn at l.
+func (me *MapEntity) spawnable(l Location) bool {
+	if l.X < 0 || l.Y < 0 || l.Y >= len(me.Matrix) || l.X >= len(me.Matrix[l.Y]) {
+		return false
+	}
+	be := me.Matrix[l.Y][l.X]
+	if be.HasResident() || !IsAccessableBlocktype(be.Blocktype) {
+		return false
+	}
+	return me.Analysis == nil || me.Analysis.InMainRegion(l.X, l.Y)
+}
+
+// spawnCandidates returns all free locations of the map's spawn points and
+// zones. Maps without them use the main region.
+func (me *MapEntity) spawnCandidates() []SpawnCandidate {
+	var candidates []SpawnCandidate
+	for _, l := range me.SpawnPoints {
+		if me.spawnable(l) {
+			candidates = append(candidates, SpawnCandidate{Location: l})
+		}
+	}
+	for _, z := range me.SpawnZones {
+		for y := z.Y; y < z.Y+z.Height; y++ {
+			for x := z.X; x < z.X+z.Width; x++ {
+				if l := (Location{X: x, Y: y}); me.spawnable(l) {
+					candidates = append(candidates, SpawnCandidate{Location: l, Team: z.Team})
+				}
+			}
+		}
+	}
+	if len(me.SpawnPoints) > 0 || len(me.SpawnZones) > 0 {
+		return candidates
+	}
+
+	if me.Analysis != nil {
+		for _, l := range me.Analysis.MainRegion() {
+			if me.spawnable(l) {
+				candidates = append(candidates, SpawnCandidate{Location: l})
+			}
+		}
+		return candidates
+	}
+	return me.scanSpawnable(len(me.Matrix) * me.Width)
+}
+
+// scanSpawnable returns up to max spawnable locations in the order of the
+// map (rows from north to south).
+func (me *MapEntity) scanSpawnable(max int) []SpawnCandidate {
+	var candidates []SpawnCandidate
+	for y, row := range me.Matrix {
+		for x := range row {
+			if len(candidates) >= max {
+				return candidates
+			}
+			if l := (Location{X: x, Y: y}); me.spawnable(l) {
+				candidates = append(candidates, SpawnCandidate{Location: l})
+			}
+		}
+	}
+	return candidates
+}
+
+// spawnLocation returns the location p spawns at. If the strategy doesn't
+// find one (e.g. all spawn points are occupied) the first free location of
+// the map is used. Only a completely occupied map fails.
+func (me *MapEntity) spawnLocation(p *Player) (Location, error) {
+	strategy := me.SpawnStrategy
+	if strategy == nil {
+		strategy = RandomSpawn{}
+	}
+
+	if l, ok := strategy.Pick(p, me, me.spawnCandidates()); ok {
+		return l, nil
+	}
+
+	if fallback := me.scanSpawnable(1); len(fallback) > 0 {
+		return fallback[0].Location, nil
+	}
+	return Location{}, errors.New("vbge: unable to find a suitable location to place the player during spawn")
+}
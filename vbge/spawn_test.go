@@ -0,0 +1,115 @@
+package vbge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSpawnTestMap(width, height int) *MapEntity {
+	blocks := make([][]string, height)
+	for y := range blocks {
+		blocks[y] = make([]string, width)
+		for x := range blocks[y] {
+			blocks[y][x] = blockGrass
+		}
+	}
+	return NewMapEntityFromMap(width, height, blocks)
+}
+
+func TestSpawn_points(t *testing.T) {
+	assert := assert.New(t)
+
+	m := newSpawnTestMap(10, 10)
+	m.SpawnPoints = []Location{{X: 1, Y: 1}, {X: 8, Y: 8}}
+
+	for i := 1; i <= 2; i++ {
+		p := &Player{UserID: i, Map: m}
+		assert.NoError(p.Spawn())
+		assert.Contains(m.SpawnPoints, *p.Location)
+	}
+
+	// all points are occupied, so the first free block is used
+	p := &Player{UserID: 3, Map: m}
+	assert.NoError(p.Spawn())
+	assert.Equal(Location{X: 0, Y: 0}, *p.Location)
+}
+
+func TestSpawn_fullMap(t *testing.T) {
+	assert := assert.New(t)
+
+	m := newSpawnTestMap(2, 1)
+	assert.NoError((&Player{UserID: 1, Map: m}).Spawn())
+	assert.NoError((&Player{UserID: 2, Map: m}).Spawn())
+	assert.Error((&Player{UserID: 3, Map: m}).Spawn())
+}
+
+func TestFarthestSpawn(t *testing.T) {
+	assert := assert.New(t)
+
+	m := newSpawnTestMap(10, 10)
+	m.SpawnStrategy = FarthestSpawn{}
+	m.Matrix[0][0].JoinArea(&Player{UserID: 1})
+
+	p := &Player{UserID: 2, Map: m}
+	assert.NoError(p.Spawn())
+	assert.Equal(Location{X: 9, Y: 9}, *p.Location)
+}
+
+func TestTeamZoneSpawn(t *testing.T) {
+	assert := assert.New(t)
+
+	m := newSpawnTestMap(10, 10)
+	m.SpawnZones = []SpawnZone{
+		{Team: "red", X: 0, Y: 0, Width: 2, Height: 2},
+		{Team: "blue", X: 8, Y: 8, Width: 2, Height: 2},
+		{X: 4, Y: 4, Width: 1, Height: 1},
+	}
+	m.SpawnStrategy = TeamZoneSpawn{Teams: map[int]string{1: "red", 2: "blue"}}
+
+	for i := 0; i < 3; i++ {
+		red := &Player{UserID: 1, Map: m}
+		assert.NoError(red.Spawn())
+		assert.True(m.SpawnZones[0].Contains(*red.Location))
+		m.Matrix[red.Location.Y][red.Location.X].LeaveArea()
+
+		blue := &Player{UserID: 2, Map: m}
+		assert.NoError(blue.Spawn())
+		assert.True(m.SpawnZones[1].Contains(*blue.Location))
+		m.Matrix[blue.Location.Y][blue.Location.X].LeaveArea()
+	}
+
+	// players without team use the neutral zone
+	p := &Player{UserID: 3, Map: m}
+	assert.NoError(p.Spawn())
+	assert.Equal(Location{X: 4, Y: 4}, *p.Location)
+}
+
+func TestRoundRobinSpawn(t *testing.T) {
+	assert := assert.New(t)
+
+	m := newSpawnTestMap(10, 10)
+	m.SpawnPoints = []Location{{X: 1, Y: 1}, {X: 2, Y: 2}, {X: 3, Y: 3}}
+	m.SpawnStrategy = &RoundRobinSpawn{}
+
+	var got []Location
+	for i := 0; i < 4; i++ {
+		p := &Player{UserID: i, Map: m}
+		assert.NoError(p.Spawn())
+		got = append(got, *p.Location)
+		m.Matrix[p.Location.Y][p.Location.X].LeaveArea()
+	}
+	assert.Equal([]Location{{X: 1, Y: 1}, {X: 2, Y: 2}, {X: 3, Y: 3}, {X: 1, Y: 1}}, got)
+}
+
+func TestNewSpawnStrategy(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, name := range []string{"", "random", "farthest", "team", "round_robin"} {
+		s, err := NewSpawnStrategy(name, nil)
+		assert.NoError(err)
+		assert.NotNil(s)
+	}
+	_, err := NewSpawnStrategy("nearest", nil)
+	assert.Error(err)
+}
@@ -23,6 +23,9 @@ type ViewableMapentity struct {
 	Height int             `json:"height"`
 	Witdh  int             `json:"width"`
 	Matrix [][]*EntityResp `json:"matrix"`
+
+	// Origin is the absolute map location of Matrix[0][0] (if known)
+	Origin *Location `json:"-"`
 }
 
 // GetViewableMapentity returns a Mapentity for a specific player
@@ -48,6 +51,14 @@ func GetViewableMapentity(width, height, userID int, game *Battle, sync bool) (v
 		Matrix: viewableMatrix,
 	}
 
+	// the player is located in the center of it's section
+	if res := gameMatrixMe[HrHeight][HrWidth].Resident; res != nil && height == RenderHeight && width == RenderWidth {
+		viewableMapentity.Origin = &Location{
+			X: res.Location.X - HrWidth,
+			Y: res.Location.Y - HrHeight,
+		}
+	}
+
 	return viewableMapentity, err
 }
 
@@ -132,3 +143,73 @@ func fillMatrixWithER(matrix [][]*EntityResp, me *MapEntity, direction string) [
 	}
 	return matrix
 }
+
+// EntityDelta is a single changed location inside a ViewableMapentity. X and Y
+// are the indices inside the matrix.
+type EntityDelta struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	*EntityResp
+}
+
+// Shift returns how far the section of next moved compared to prev. It's
+// zero if the origin of one of them is unknown.
+func (vme *ViewableMapentity) Shift(next *ViewableMapentity) (dx, dy int) {
+	if vme == nil || vme.Origin == nil || next.Origin == nil {
+		return 0, 0
+	}
+	return next.Origin.X - vme.Origin.X, next.Origin.Y - vme.Origin.Y
+}
+
+// Diff returns all locations of next that differ from the same map location
+// in prev. Locations are compared after shifting prev by Shift, so a moving
+// player only produces the newly visible line and the changed players. If the
+// dimensions of both mapentities don't match every location of next is
+// returned. The X and Y of the deltas are relative to next.
+func (vme *ViewableMapentity) Diff(next *ViewableMapentity) []EntityDelta {
+	sameSize := vme != nil && vme.Height == next.Height && vme.Witdh == next.Witdh
+	dx, dy := vme.Shift(next)
+
+	var deltas []EntityDelta
+	for y := range next.Matrix {
+		for x := range next.Matrix[y] {
+			px, py := x+dx, y+dy
+			inside := py >= 0 && py < len(next.Matrix) && px >= 0 && px < len(next.Matrix[y])
+			if sameSize && inside && next.Matrix[y][x].Equal(vme.Matrix[py][px]) {
+				continue
+			}
+			deltas = append(deltas, EntityDelta{
+				X:          x,
+				Y:          y,
+				EntityResp: next.Matrix[y][x],
+			})
+		}
+	}
+	return deltas
+}
+
+// Equal reports whether both EntityResps describe the same blocktype and
+// player state.
+func (er *EntityResp) Equal(o *EntityResp) bool {
+	if er == nil || o == nil {
+		return er == o
+	}
+	if er.Blocktype != o.Blocktype {
+		return false
+	}
+	return er.Player.Equal(o.Player)
+}
+
+// Equal reports whether both PlayerResps describe the same player state.
+func (pr *PlayerResp) Equal(o *PlayerResp) bool {
+	if pr == nil || o == nil {
+		return pr == o
+	}
+	if pr.GRID != o.GRID || pr.Health != o.Health || pr.CharacterType != o.CharacterType || pr.WatchDir != o.WatchDir {
+		return false
+	}
+	if pr.Location == nil || o.Location == nil {
+		return pr.Location == o.Location
+	}
+	return *pr.Location == *o.Location
+}
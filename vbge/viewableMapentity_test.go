@@ -0,0 +1,88 @@
+package vbge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestViewableMapentity(height, width int) *ViewableMapentity {
+	matrix := make([][]*EntityResp, height)
+	for y := range matrix {
+		matrix[y] = make([]*EntityResp, width)
+		for x := range matrix[y] {
+			matrix[y][x] = &EntityResp{Blocktype: blockGrass}
+		}
+	}
+	return &ViewableMapentity{
+		Height: height,
+		Witdh:  width,
+		Matrix: matrix,
+	}
+}
+
+func TestViewableMapentity_Diff(t *testing.T) {
+	prev := newTestViewableMapentity(3, 3)
+
+	t.Run("unchanged", func(t *testing.T) {
+		next := newTestViewableMapentity(3, 3)
+		assert.Len(t, prev.Diff(next), 0)
+	})
+
+	t.Run("blocktype and player changed", func(t *testing.T) {
+		next := newTestViewableMapentity(3, 3)
+		next.Matrix[0][1].Blocktype = blockWater
+		next.Matrix[2][2].Player = &PlayerResp{
+			GRID:     "1",
+			Health:   MaxHealth,
+			Location: (&Location{X: 1, Y: 1}).ToARLocation(),
+		}
+
+		deltas := prev.Diff(next)
+		assert.Equal(t, []EntityDelta{
+			{X: 1, Y: 0, EntityResp: next.Matrix[0][1]},
+			{X: 2, Y: 2, EntityResp: next.Matrix[2][2]},
+		}, deltas)
+	})
+
+	t.Run("player state changed", func(t *testing.T) {
+		withPlayer := newTestViewableMapentity(3, 3)
+		withPlayer.Matrix[1][1].Player = &PlayerResp{GRID: "1", Health: MaxHealth, WatchDir: dirNorth}
+
+		next := newTestViewableMapentity(3, 3)
+		next.Matrix[1][1].Player = &PlayerResp{GRID: "1", Health: MaxHealth - defaultDmg, WatchDir: dirNorth}
+
+		assert.Len(t, withPlayer.Diff(next), 1)
+	})
+
+	t.Run("different dimensions", func(t *testing.T) {
+		next := newTestViewableMapentity(2, 2)
+		assert.Len(t, prev.Diff(next), 4)
+	})
+
+	t.Run("shifted origin", func(t *testing.T) {
+		from := newTestViewableMapentity(3, 3)
+		from.Origin = &Location{X: 4, Y: 4}
+		from.Matrix[0][0].Blocktype = blockWater
+
+		// the section moved one column to the east. The water left it and
+		// only the new column differs
+		next := newTestViewableMapentity(3, 3)
+		next.Origin = &Location{X: 5, Y: 4}
+		next.Matrix[1][2].Blocktype = blockWater
+
+		dx, dy := from.Shift(next)
+		assert.Equal(t, 1, dx)
+		assert.Equal(t, 0, dy)
+		assert.Equal(t, []EntityDelta{
+			{X: 2, Y: 0, EntityResp: next.Matrix[0][2]},
+			{X: 2, Y: 1, EntityResp: next.Matrix[1][2]},
+			{X: 2, Y: 2, EntityResp: next.Matrix[2][2]},
+		}, from.Diff(next))
+	})
+
+	t.Run("nil previous", func(t *testing.T) {
+		var none *ViewableMapentity
+		assert.Len(t, none.Diff(prev), 9)
+	})
+}